@@ -0,0 +1,86 @@
+package bouncehandler
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/apex/log"
+	"gjhr.me/newsletter/providers/config"
+	"gjhr.me/newsletter/utils/consterror"
+	"gjhr.me/newsletter/utils/snsmessage"
+)
+
+const (
+	ERR_UNEXPECTED_TOPIC = consterror.ConstError("SNS message is from an unexpected topic")
+)
+
+// HandleSNS verifies an SNS message carrying SES notifications and handles
+// it. Subscriptions to the topic are confirmed automatically.
+func HandleSNS(m *snsmessage.Message) error {
+	logger := log.WithFields(log.Fields{
+		"sns_message": m.MessageId,
+		"topic":       m.TopicArn,
+		"type":        m.Type,
+	})
+	if !AllowedTopic(m.TopicArn) {
+		logger.Warn("Rejecting SNS message from unexpected topic")
+		return ERR_UNEXPECTED_TOPIC
+	}
+	err := m.Verify()
+	if err != nil {
+		logger.WithError(err).Warn("Rejecting SNS message with invalid signature")
+		return err
+	}
+
+	switch m.Type {
+	case snsmessage.TYPE_SUBSCRIPTION_CONFIRMATION:
+		logger.Info("Confirming SNS subscription")
+		return m.ConfirmSubscription()
+	case snsmessage.TYPE_UNSUBSCRIBE_CONFIRMATION:
+		logger.Warn("Unsubscribed from SNS topic")
+		return nil
+	}
+	return HandleNotification(m.Message)
+}
+
+// AllowedTopic reports whether messages from the topic are accepted. Any
+// topic is when none are configured, as Lambda subscriptions cannot be made
+// by anyone else.
+func AllowedTopic(topicArn string) bool {
+	allowed := config.Get().SnsTopicArns
+	if allowed == "" {
+		return true
+	}
+	for _, arn := range strings.Split(allowed, ",") {
+		if strings.TrimSpace(arn) == topicArn {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleNotification records an SES notification and acts on bounces,
+// complaints and deliveries.
+func HandleNotification(raw string) error {
+	log.Debug(raw)
+	var message Message
+	err := json.Unmarshal([]byte(raw), &message)
+	if err != nil {
+		return err
+	}
+	err = recordEvent(&message)
+	if err != nil {
+		// Keep handling the notification, unsubscribing matters more. Failing
+		// would have it redelivered and acted on again for a lost event.
+		log.WithError(err).Errorf("Failed to record %v event", message.Type())
+	}
+	switch {
+	case message.Bounce != nil:
+		return handleBounce(&message)
+	case message.Complaint != nil:
+		return handleComplaint(&message)
+	case message.Delivery != nil:
+		return handleDelivery(&message)
+	}
+	return nil
+}
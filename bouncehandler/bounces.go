@@ -0,0 +1,126 @@
+package bouncehandler
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"gjhr.me/newsletter/data/suppression"
+	"gjhr.me/newsletter/emailsender"
+	"gjhr.me/newsletter/providers/storage"
+)
+
+// What to do about a recipient a mail bounced for.
+type bounceAction int
+
+const (
+	// The address will never accept mail, stop sending to it
+	actionSuppress bounceAction = iota
+	// The address may accept mail later, count it towards suspension
+	actionSoftBounce
+	// The mail was refused rather than the address, e.g. for its size
+	actionIgnore
+)
+
+// Diagnostics of permanent bounces which are really about a full mailbox.
+var mailboxFullDiagnostic = regexp.MustCompile(`(?i)\b5\.2\.2\b|mailbox (is )?full|over ?quota|quota exceeded|insufficient (disk )?space`)
+
+// bouncePolicy decides what to do about a bounced recipient from the bounce
+// sub-type and the diagnostic the receiving server gave.
+func bouncePolicy(bounceType BounceType, subType BounceSubType, diagnostic string) bounceAction {
+	switch subType {
+	case BounceSubTypeMessageTooLarge, BounceSubTypeContentRejected, BounceSubTypeAttachmentRejected:
+		return actionIgnore
+	case BounceSubTypeMailboxFull:
+		return actionSoftBounce
+	}
+	if bounceType != BounceTypePermanent {
+		return actionSoftBounce
+	}
+	switch subType {
+	case BounceSubTypeNoEmail, BounceSubTypeSuppressed, BounceSubTypeOnAccountSuppressionList:
+		return actionSuppress
+	}
+	// Some servers report full mailboxes as permanent failures
+	if mailboxFullDiagnostic.MatchString(diagnostic) {
+		return actionSoftBounce
+	}
+	return actionSuppress
+}
+
+// handleBounce applies the bounce policy to each recipient the mail bounced
+// for, leaving any other recipients of the mail be.
+func handleBounce(message *Message) error {
+	bounce := message.Bounce
+	list := message.Mail.header(emailsender.HEADER_LIST)
+	timestamp := bounce.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	var lastErr error
+	for _, recipient := range bounce.BouncedRecipients {
+		diagnostic := formatDiagnostic(string(bounce.BounceType)+"/"+string(bounce.BounceSubType), recipient.Status, recipient.DiagnosticCode)
+		logger := log.WithFields(log.Fields{
+			"recipient":  recipient.EmailAddress,
+			"diagnostic": diagnostic,
+		})
+		var err error
+		switch bouncePolicy(bounce.BounceType, bounce.BounceSubType, recipient.DiagnosticCode) {
+		case actionSuppress:
+			logger.Info("Suppressing bounced recipient")
+			err = suppress(recipient.EmailAddress, suppression.REASON_BOUNCE, message.Mail.MessageId, timestamp, diagnostic)
+		case actionSoftBounce:
+			logger.Info("Counting soft bounce")
+			err = handleSoftBounce(recipient.EmailAddress, list, message.Mail.MessageId, timestamp)
+		case actionIgnore:
+			logger.Info("Ignoring bounce of the mail rather than the recipient")
+		}
+		if err != nil {
+			logger.WithError(err).Error("Failed to handle bounce")
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// handleComplaint suppresses the recipients who reported the mail, unless the
+// report says it is not spam.
+func handleComplaint(message *Message) error {
+	complaint := message.Complaint
+	if complaint.ComplaintFeedbackType == "not-spam" {
+		return nil
+	}
+	timestamp := complaint.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	var lastErr error
+	for _, recipient := range complaint.ComplainedRecipients {
+		log.WithField("recipient", recipient.EmailAddress).Info("Suppressing complaining recipient")
+		err := suppress(recipient.EmailAddress, suppression.REASON_COMPLAINT, message.Mail.MessageId, timestamp, formatDiagnostic(complaint.ComplaintFeedbackType, complaint.UserAgent))
+		if err != nil {
+			log.WithError(err).WithField("recipient", recipient.EmailAddress).Error("Failed to handle complaint")
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// suppress stops all mail to the address, keeping its subscriptions.
+func suppress(email string, reason string, messageID string, timestamp time.Time, diagnostic string) error {
+	s := suppression.New(email, reason, messageID, timestamp)
+	s.Diagnostic = diagnostic
+	return storage.Suppressions().Put(s)
+}
+
+// formatDiagnostic joins the non empty parts of a diagnostic for audit.
+func formatDiagnostic(parts ...string) string {
+	nonEmpty := []string{}
+	for _, part := range parts {
+		if part = strings.TrimSpace(part); part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+	return strings.Join(nonEmpty, "; ")
+}
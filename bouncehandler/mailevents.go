@@ -0,0 +1,94 @@
+package bouncehandler
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+
+	"gjhr.me/newsletter/data/mailevents"
+	"gjhr.me/newsletter/emailsender"
+	"gjhr.me/newsletter/providers/storage"
+)
+
+// recordEvent stores a notification against the message it is about, along
+// with the list and issue the message was sent for.
+func recordEvent(message *Message) error {
+	if message.Mail.MessageId == "" {
+		return fmt.Errorf("Notification of type '%v' has no message ID", message.Type())
+	}
+	timestamp, recipients, detail := eventDetails(message)
+	e := mailevents.New(message.Mail.MessageId, string(message.Type()), timestamp)
+	e.List = message.Mail.header(emailsender.HEADER_LIST)
+	e.Issue = message.Mail.header(emailsender.HEADER_ISSUE)
+	e.Recipients = recipients
+	e.Detail = detail
+	return storage.MailEvents().Put(e)
+}
+
+// eventDetails picks the time, recipients and a short description out of the
+// part of the message specific to its type.
+func eventDetails(message *Message) (timestamp time.Time, recipients []string, detail string) {
+	timestamp, _ = time.Parse(time.RFC3339, message.Mail.Timestamp)
+	recipients = message.Mail.Destination
+	switch {
+	case message.Bounce != nil:
+		timestamp = message.Bounce.Timestamp
+		recipients = []string{}
+		for _, r := range message.Bounce.BouncedRecipients {
+			recipients = append(recipients, r.EmailAddress)
+		}
+		detail = fmt.Sprintf("%v/%v", message.Bounce.BounceType, message.Bounce.BounceSubType)
+		for _, r := range message.Bounce.BouncedRecipients {
+			if r.DiagnosticCode != "" {
+				detail += fmt.Sprintf("; %v: %v", r.EmailAddress, r.DiagnosticCode)
+			}
+		}
+	case message.Complaint != nil:
+		timestamp = message.Complaint.Timestamp
+		recipients = []string{}
+		for _, r := range message.Complaint.ComplainedRecipients {
+			recipients = append(recipients, r.EmailAddress)
+		}
+		detail = message.Complaint.ComplaintFeedbackType
+	case message.Delivery != nil:
+		timestamp = message.Delivery.Timestamp
+		recipients = message.Delivery.Recipients
+		detail = message.Delivery.SmtpResponse
+	case message.DeliveryDelay != nil:
+		timestamp = message.DeliveryDelay.Timestamp
+		recipients = []string{}
+		for _, r := range message.DeliveryDelay.DelayedRecipients {
+			recipients = append(recipients, r.EmailAddress)
+		}
+		detail = message.DeliveryDelay.DelayType
+	case message.Open != nil:
+		timestamp = message.Open.Timestamp
+	case message.Click != nil:
+		timestamp = message.Click.Timestamp
+		detail = message.Click.Link
+	case message.Reject != nil:
+		detail = message.Reject.Reason
+	case message.Failure != nil:
+		detail = message.Failure.ErrorMessage
+	}
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	return
+}
+
+// header returns the decoded value of an original header of the mail, if SES
+// included it.
+func (m *Mail) header(name string) string {
+	for _, h := range m.Headers {
+		if strings.EqualFold(h.Name, name) {
+			decoded, err := new(mime.WordDecoder).DecodeHeader(h.Value)
+			if err != nil {
+				return h.Value
+			}
+			return decoded
+		}
+	}
+	return ""
+}
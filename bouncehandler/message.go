@@ -1,4 +1,4 @@
-package main
+package bouncehandler
 
 /*
 Taken from https://github.com/web-ridge/sns_ses/blob/main/sns_ses.go with MIT license:
@@ -36,6 +36,13 @@ const (
 	NotificationTypeComplaint NotificationType = "Complaint"
 	NotificationTypeDelivery  NotificationType = "Delivery"
 	NotificationTypeReceived  NotificationType = "Received"
+	// Only published through configuration sets
+	NotificationTypeSend             NotificationType = "Send"
+	NotificationTypeReject           NotificationType = "Reject"
+	NotificationTypeOpen             NotificationType = "Open"
+	NotificationTypeClick            NotificationType = "Click"
+	NotificationTypeDeliveryDelay    NotificationType = "DeliveryDelay"
+	NotificationTypeRenderingFailure NotificationType = "Rendering Failure"
 )
 
 type BounceType string
@@ -49,9 +56,15 @@ const (
 type BounceSubType string
 
 const (
-	BounceSubTypeUndetermined BounceSubType = "Undetermined"
-	BounceSubTypeGeneral      BounceSubType = "General"
-	BounceSubTypeNoEmail      BounceSubType = "NoEmail"
+	BounceSubTypeUndetermined             BounceSubType = "Undetermined"
+	BounceSubTypeGeneral                  BounceSubType = "General"
+	BounceSubTypeNoEmail                  BounceSubType = "NoEmail"
+	BounceSubTypeSuppressed               BounceSubType = "Suppressed"
+	BounceSubTypeOnAccountSuppressionList BounceSubType = "OnAccountSuppressionList"
+	BounceSubTypeMailboxFull              BounceSubType = "MailboxFull"
+	BounceSubTypeMessageTooLarge          BounceSubType = "MessageTooLarge"
+	BounceSubTypeContentRejected          BounceSubType = "ContentRejected"
+	BounceSubTypeAttachmentRejected       BounceSubType = "AttachmentRejected"
 )
 
 type Body struct {
@@ -64,11 +77,27 @@ type Body struct {
 
 type Message struct {
 	NotificationType NotificationType `json:"notificationType"`
-	Mail             Mail             `json:"mail"`
-	Bounce           *Bounce          `json:"bounce"`
-	Complaint        *Complaint       `json:"complaint"`
-	Delivery         *Delivery        `json:"delivery"`
-	Receipt          *Receipt         `json:"receipt"`
+	// Set instead of NotificationType by configuration set event publishing
+	EventType     NotificationType `json:"eventType"`
+	Mail          Mail             `json:"mail"`
+	Bounce        *Bounce          `json:"bounce"`
+	Complaint     *Complaint       `json:"complaint"`
+	Delivery      *Delivery        `json:"delivery"`
+	Receipt       *Receipt         `json:"receipt"`
+	Send          *struct{}        `json:"send"`
+	Reject        *Reject          `json:"reject"`
+	Open          *Open            `json:"open"`
+	Click         *Click           `json:"click"`
+	DeliveryDelay *DeliveryDelay   `json:"deliveryDelay"`
+	Failure       *Failure         `json:"failure"`
+}
+
+// Type is the type of the notification or event, whichever was published.
+func (m *Message) Type() NotificationType {
+	if m.EventType != "" {
+		return m.EventType
+	}
+	return m.NotificationType
 }
 
 type Receipt struct {
@@ -155,3 +184,37 @@ type Delivery struct {
 	ReportingMTA         string    `json:"reportingMTA"`
 	RemoteMtaIp          string    `json:"remoteMtaIp"`
 }
+
+type Reject struct {
+	Reason string `json:"reason"`
+}
+
+type Open struct {
+	IpAddress string    `json:"ipAddress"`
+	Timestamp time.Time `json:"timestamp"`
+	UserAgent string    `json:"userAgent"`
+}
+
+type Click struct {
+	IpAddress string              `json:"ipAddress"`
+	Timestamp time.Time           `json:"timestamp"`
+	UserAgent string              `json:"userAgent"`
+	Link      string              `json:"link"`
+	LinkTags  map[string][]string `json:"linkTags"`
+}
+
+type DeliveryDelay struct {
+	Timestamp         time.Time `json:"timestamp"`
+	DelayType         string    `json:"delayType"`
+	ExpirationTime    time.Time `json:"expirationTime"`
+	DelayedRecipients []struct {
+		EmailAddress   string `json:"emailAddress"`
+		Status         string `json:"status"`
+		DiagnosticCode string `json:"diagnosticCode"`
+	} `json:"delayedRecipients"`
+}
+
+type Failure struct {
+	ErrorMessage string `json:"errorMessage"`
+	TemplateName string `json:"templateName"`
+}
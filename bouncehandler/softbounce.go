@@ -0,0 +1,96 @@
+package bouncehandler
+
+import (
+	"time"
+
+	"github.com/apex/log"
+	"gjhr.me/newsletter/data/subscription"
+	"gjhr.me/newsletter/emailsender"
+	"gjhr.me/newsletter/providers/storage"
+)
+
+// handleSoftBounce counts a transient bounce of a mail against the
+// subscriptions of the address, suspending those which bounced too often
+// recently. Bounces already counted for the mail are not counted again, as
+// notifications are redelivered when handling them fails.
+func handleSoftBounce(email string, list string, messageID string, at time.Time) error {
+	subs, err := subscriptionsFor(email, list)
+	if err != nil {
+		return err
+	}
+	var lastErr error
+	for _, sub := range subs {
+		err = countSoftBounce(sub, messageID, at)
+		if err != nil {
+			log.WithError(err).Warnf("Failed to count soft bounce for %v on %v", sub.Email, sub.List)
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func countSoftBounce(sub *subscription.Subscription, messageID string, at time.Time) error {
+	lst, err := storage.Lists().Get(sub.List)
+	if err != nil {
+		return err
+	}
+	limit, window := lst.SoftBounceThreshold()
+	count := sub.AddSoftBounce(messageID, at, window)
+	err = storage.Subscriptions().UpdateSoftBounces(sub)
+	if err != nil {
+		return err
+	}
+	if count >= limit && !sub.Suspended {
+		log.Infof("Suspending %v on %v after %v soft bounces", sub.Email, sub.List, count)
+		return storage.Subscriptions().Suspend(sub)
+	}
+	return nil
+}
+
+// handleDelivery reactivates suspended subscriptions of recipients which mail
+// has been delivered to again. Suspended subscriptions are not sent their
+// list's mail, so delivery of any mail to the address reactivates all of them,
+// while soft bounces are only forgotten for the list the mail was sent for.
+func handleDelivery(message *Message) error {
+	list := message.Mail.header(emailsender.HEADER_LIST)
+	var lastErr error
+	for _, recipient := range message.Delivery.Recipients {
+		subs, err := subscriptionsFor(recipient, "")
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		for _, sub := range subs {
+			bounced := len(sub.SoftBounces) > 0 && (list == "" || sub.List == list)
+			if !sub.Suspended && !bounced {
+				continue
+			}
+			log.Infof("Reactivating %v on %v after a delivery", sub.Email, sub.List)
+			err = storage.Subscriptions().Reactivate(sub)
+			if err != nil {
+				lastErr = err
+			}
+		}
+	}
+	return lastErr
+}
+
+// subscriptionsFor returns the subscription of the address to the list the
+// mail was sent for, or all of its subscriptions if that is not known.
+func subscriptionsFor(email string, list string) ([]*subscription.Subscription, error) {
+	if list != "" {
+		sub, err := storage.Subscriptions().Get(list, email)
+		if err == subscription.ERR_SUBSCRIPTION_NOT_FOUND {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return []*subscription.Subscription{sub}, nil
+	}
+	subs, err := storage.Subscriptions().GetAllForEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	return *subs, nil
+}
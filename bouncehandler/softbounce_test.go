@@ -0,0 +1,128 @@
+package bouncehandler
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"gjhr.me/newsletter/data/list"
+	"gjhr.me/newsletter/data/subscription"
+	"gjhr.me/newsletter/providers/storage"
+)
+
+func softBounceNotification(messageID string, email string, listName string, at time.Time) string {
+	return fmt.Sprintf(`{
+		"notificationType": "Bounce",
+		"mail": {"messageId": %q, "headers": [{"name": "X-Newsletter-List", "value": %q}]},
+		"bounce": {
+			"bounceType": "Transient",
+			"bounceSubType": "MailboxFull",
+			"bouncedRecipients": [{"emailAddress": %q, "status": "4.2.2"}],
+			"timestamp": %q
+		}
+	}`, messageID, listName, email, at.Format(time.RFC3339))
+}
+
+func deliveryNotification(messageID string, email string, listName string) string {
+	return fmt.Sprintf(`{
+		"notificationType": "Delivery",
+		"mail": {"messageId": %q, "headers": [{"name": "X-Newsletter-List", "value": %q}]},
+		"delivery": {"recipients": [%q]}
+	}`, messageID, listName, email)
+}
+
+func setUpSubscriptions(t *testing.T, subs ...*subscription.Subscription) {
+	t.Helper()
+	err := storage.Use("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"busy", "quiet"} {
+		err = storage.Lists().Put(&list.List{Name: name, SoftBounceLimit: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, sub := range subs {
+		err = storage.Subscriptions().Put(sub)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func getSubscription(t *testing.T, listName string, email string) *subscription.Subscription {
+	t.Helper()
+	sub, err := storage.Subscriptions().Get(listName, email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sub
+}
+
+func TestSuspendThenReactivate(t *testing.T) {
+	email := "reader@example.com"
+	now := time.Now()
+	tests := []struct {
+		name     string
+		delivery string
+		// Whether the delivery should reactivate the suspended subscription
+		reactivates bool
+	}{
+		{"delivery for the suspended list", deliveryNotification("delivered", email, "quiet"), true},
+		{"delivery for another list", deliveryNotification("delivered", email, "busy"), true},
+		{"delivery of mail for no list", deliveryNotification("delivered", email, ""), true},
+		{"delivery to someone else", deliveryNotification("delivered", "other@example.com", "quiet"), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			setUpSubscriptions(t,
+				&subscription.Subscription{Email: email, List: "quiet", Verified: "yes", VerificationToken: "1"},
+				&subscription.Subscription{Email: email, List: "busy", Verified: "yes", VerificationToken: "2"},
+			)
+			for i := 0; i < 2; i++ {
+				err := HandleNotification(softBounceNotification(fmt.Sprintf("bounce-%v", i), email, "quiet", now.Add(time.Duration(i)*time.Minute)))
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !getSubscription(t, "quiet", email).Suspended {
+				t.Fatal("subscription not suspended after reaching the soft bounce limit")
+			}
+			if getSubscription(t, "busy", email).Suspended {
+				t.Fatal("subscription to another list suspended")
+			}
+
+			err := HandleNotification(test.delivery)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sub := getSubscription(t, "quiet", email)
+			if sub.Suspended == test.reactivates {
+				t.Errorf("suspended = %v after delivery, want %v", sub.Suspended, !test.reactivates)
+			}
+			if test.reactivates && len(sub.SoftBounces) != 0 {
+				t.Errorf("soft bounces kept after reactivation: %v", sub.SoftBounces)
+			}
+		})
+	}
+}
+
+func TestRedeliveredSoftBounceCountedOnce(t *testing.T) {
+	email := "reader@example.com"
+	setUpSubscriptions(t, &subscription.Subscription{Email: email, List: "quiet", Verified: "yes", VerificationToken: "1"})
+	notification := softBounceNotification("bounce", email, "quiet", time.Now())
+	for i := 0; i < 3; i++ {
+		err := HandleNotification(notification)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	sub := getSubscription(t, "quiet", email)
+	if len(sub.SoftBounces) != 1 {
+		t.Errorf("counted %v soft bounces of one mail, want 1", len(sub.SoftBounces))
+	}
+	if sub.Suspended {
+		t.Error("suspended after soft bounces of one mail")
+	}
+}
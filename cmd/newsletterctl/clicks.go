@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mmcdole/gofeed"
+	"gjhr.me/newsletter/issue"
+	"gjhr.me/newsletter/providers/storage"
+)
+
+var clickCommands = map[string]command{
+	"ls": {"List the clicks on each link of an issue.", clicksLs},
+}
+
+func clicksLs(args []string) error {
+	fs := newFlagSet("clicks ls")
+	listName := fs.String("list", "", "Name of the list.")
+	guid := fs.String("guid", "", "GUID of the feed item the issue was sent for.")
+	asJSON := fs.Bool("json", false, "Print the clicks as JSON, including subscriber hashes.")
+	fs.Parse(args)
+	err := required(map[string]string{"list": *listName, "guid": *guid})
+	if err != nil {
+		return err
+	}
+
+	lst, err := storage.Lists().Get(*listName)
+	if err != nil {
+		return err
+	}
+	links, err := storage.Clicks().GetForIssue(issue.ID(lst, &gofeed.Item{GUID: *guid}))
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(links)
+	}
+	for _, c := range *links {
+		fmt.Printf("%v\t%v unique\t%v\n", c.Clicks, len(c.Subscribers), c.Link)
+	}
+	return nil
+}
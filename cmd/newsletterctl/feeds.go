@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"gjhr.me/newsletter/data/list"
+	"gjhr.me/newsletter/providers/storage"
+)
+
+var feedCommands = map[string]command{
+	"ls":     {"List the feeds of a list.", feedLs},
+	"add":    {"Add a feed URL to a list.", feedAdd},
+	"update": {"Set the name and slug of a feed.", feedUpdate},
+	"remove": {"Remove a feed URL from a list.", feedRemove},
+	"reset":  {"Forget the processed items of a feed so they can be sent again.", feedReset},
+}
+
+func feedFlags(name string, args []string) (*list.List, list.Feed, *flag.FlagSet, error) {
+	fs := newFlagSet(name)
+	listName := fs.String("list", "", "Name of the list.")
+	var feed list.Feed
+	fs.StringVar(&feed.Url, "url", "", "URL of the feed.")
+	fs.StringVar(&feed.Name, "name", "", "Name subscribers choose the feed by.")
+	fs.StringVar(&feed.Slug, "slug", "", "Short identifier of the feed in links, such as 'release-notes'.")
+	fs.Parse(args)
+	err := required(map[string]string{"list": *listName, "url": feed.Url})
+	if err != nil {
+		return nil, feed, nil, err
+	}
+	lst, err := storage.Lists().Get(*listName)
+	if err != nil {
+		return nil, feed, nil, err
+	}
+	return lst, feed, fs, nil
+}
+
+func findFeed(lst *list.List, url string) (int, error) {
+	for i, feed := range lst.Feeds {
+		if feed.Url == url {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("list '%v' has no feed '%v'", lst.Name, url)
+}
+
+func feedLs(args []string) error {
+	fs := newFlagSet("feed ls")
+	listName := fs.String("list", "", "Name of the list.")
+	fs.Parse(args)
+	err := required(map[string]string{"list": *listName})
+	if err != nil {
+		return err
+	}
+	lst, err := storage.Lists().Get(*listName)
+	if err != nil {
+		return err
+	}
+	for _, feed := range lst.Feeds {
+		fmt.Printf("%v\t%v\t%v\n", feed.Key(), feed.DisplayName(), feed.Url)
+	}
+	return nil
+}
+
+func feedAdd(args []string) error {
+	lst, feed, _, err := feedFlags("feed add", args)
+	if err != nil {
+		return err
+	}
+	if _, err := findFeed(lst, feed.Url); err == nil {
+		return fmt.Errorf("list '%v' already has feed '%v'", lst.Name, feed.Url)
+	}
+	lst.Feeds = append(lst.Feeds, feed)
+	return saveList(lst)
+}
+
+func feedUpdate(args []string) error {
+	lst, feed, fs, err := feedFlags("feed update", args)
+	if err != nil {
+		return err
+	}
+	i, err := findFeed(lst, feed.Url)
+	if err != nil {
+		return err
+	}
+	if isSet(fs, "name") {
+		lst.Feeds[i].Name = feed.Name
+	}
+	if isSet(fs, "slug") {
+		// Subscribers' choices of the feed are kept by its key
+		if lst.Feeds[i].Key() != feed.Key() {
+			fmt.Printf("Subscribers who chose '%v' by its old key will no longer be sent it\n", feed.Url)
+		}
+		lst.Feeds[i].Slug = feed.Slug
+	}
+	return saveList(lst)
+}
+
+func feedRemove(args []string) error {
+	lst, feed, _, err := feedFlags("feed remove", args)
+	if err != nil {
+		return err
+	}
+	i, err := findFeed(lst, feed.Url)
+	if err != nil {
+		return err
+	}
+	lst.Feeds = append(lst.Feeds[:i], lst.Feeds[i+1:]...)
+	return saveList(lst)
+}
+
+func feedReset(args []string) error {
+	lst, feed, _, err := feedFlags("feed reset", args)
+	if err != nil {
+		return err
+	}
+	i, err := findFeed(lst, feed.Url)
+	if err != nil {
+		return err
+	}
+	lst.Feeds[i].ProcessedGuids = []string{}
+	lst.Feeds[i].LastUpdated = time.Time{}
+	return saveList(lst)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"gjhr.me/newsletter/itemfilter"
+	"gjhr.me/newsletter/providers/storage"
+)
+
+var filterCommands = map[string]command{
+	"ls":     {"List the rules deciding which items of a list, or a subscriber with -email, are sent.", filterLs},
+	"add":    {"Add a rule items must match, or with -exclude must not match, to be sent.", filterAdd},
+	"remove": {"Remove a rule by its kind and index, as shown by ls.", filterRemove},
+	"clear":  {"Remove all rules so every item is sent.", filterClear},
+}
+
+// filterTarget is the filter of a list or of one of its subscribers.
+type filterTarget struct {
+	filter *itemfilter.Filter
+	save   func(filter *itemfilter.Filter) error
+}
+
+func filterFlags(fs *flag.FlagSet, args []string) (*filterTarget, error) {
+	listName := fs.String("list", "", "Name of the list.")
+	email := fs.String("email", "", "Address of a subscriber, to filter only the items they are sent.")
+	fs.Parse(args)
+	err := required(map[string]string{"list": *listName})
+	if err != nil {
+		return nil, err
+	}
+	lst, err := storage.Lists().Get(*listName)
+	if err != nil {
+		return nil, err
+	}
+	if *email == "" {
+		return &filterTarget{lst.Filter, func(filter *itemfilter.Filter) error {
+			lst.Filter = filter
+			return saveList(lst)
+		}}, nil
+	}
+	sub, err := storage.Subscriptions().Get(lst.Name, *email)
+	if err != nil {
+		return nil, err
+	}
+	return &filterTarget{sub.Filter, func(filter *itemfilter.Filter) error {
+		err := filter.Validate()
+		if err != nil {
+			return err
+		}
+		sub.Filter = filter
+		return storage.Subscriptions().UpdateFilter(sub)
+	}}, nil
+}
+
+// saveFilter saves a filter, forgetting it once it has no rules.
+func (t *filterTarget) saveFilter(filter *itemfilter.Filter) error {
+	if filter.IsEmpty() {
+		filter = nil
+	}
+	return t.save(filter)
+}
+
+func filterLs(args []string) error {
+	target, err := filterFlags(newFlagSet("filter ls"), args)
+	if err != nil {
+		return err
+	}
+	if target.filter.IsEmpty() {
+		fmt.Println("No rules, every item is sent")
+		return nil
+	}
+	for i, rule := range target.filter.Include {
+		fmt.Printf("include\t%v\t%v\n", i, describeRule(rule))
+	}
+	for i, rule := range target.filter.Exclude {
+		fmt.Printf("exclude\t%v\t%v\n", i, describeRule(rule))
+	}
+	return nil
+}
+
+func describeRule(rule itemfilter.Rule) string {
+	conditions := []string{}
+	if len(rule.Categories) > 0 {
+		conditions = append(conditions, "category="+strings.Join(rule.Categories, ","))
+	}
+	if rule.Title != "" {
+		conditions = append(conditions, "title="+rule.Title)
+	}
+	if len(rule.Authors) > 0 {
+		conditions = append(conditions, "author="+strings.Join(rule.Authors, ","))
+	}
+	if len(rule.Keywords) > 0 {
+		conditions = append(conditions, "keyword="+strings.Join(rule.Keywords, ","))
+	}
+	return strings.Join(conditions, " ")
+}
+
+func filterAdd(args []string) error {
+	fs := newFlagSet("filter add")
+	exclude := fs.Bool("exclude", false, "Stop items matching the rule being sent, rather than sending only matching items.")
+	var rule itemfilter.Rule
+	var categories, authors, keywords stringsFlag
+	fs.Var(&categories, "category", "Category the item must have, may be repeated to match any of them.")
+	fs.StringVar(&rule.Title, "title", "", "Regular expression the item's title must match.")
+	fs.Var(&authors, "author", "Name or address of the item's author, may be repeated to match any of them.")
+	fs.Var(&keywords, "keyword", "Word or phrase the item's text must contain, may be repeated to match any of them.")
+	target, err := filterFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	rule.Categories, rule.Authors, rule.Keywords = categories, authors, keywords
+	err = rule.Validate()
+	if err != nil {
+		return err
+	}
+	filter := target.filter
+	if filter == nil {
+		filter = &itemfilter.Filter{}
+	}
+	if *exclude {
+		filter.Exclude = append(filter.Exclude, rule)
+	} else {
+		filter.Include = append(filter.Include, rule)
+	}
+	return target.saveFilter(filter)
+}
+
+func filterRemove(args []string) error {
+	fs := newFlagSet("filter remove")
+	exclude := fs.Bool("exclude", false, "Remove an exclude rule rather than an include rule.")
+	index := fs.Int("index", -1, "Index of the rule, as shown by ls.")
+	target, err := filterFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	if target.filter == nil {
+		return fmt.Errorf("there are no rules to remove")
+	}
+	rules := &target.filter.Include
+	if *exclude {
+		rules = &target.filter.Exclude
+	}
+	if *index < 0 || *index >= len(*rules) {
+		return fmt.Errorf("there is no rule %v", *index)
+	}
+	*rules = append((*rules)[:*index], (*rules)[*index+1:]...)
+	return target.saveFilter(target.filter)
+}
+
+func filterClear(args []string) error {
+	target, err := filterFlags(newFlagSet("filter clear"), args)
+	if err != nil {
+		return err
+	}
+	return target.saveFilter(nil)
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"gjhr.me/newsletter/data/list"
+	"gjhr.me/newsletter/listtemplates"
+	"gjhr.me/newsletter/providers/storage"
+)
+
+var listCommands = map[string]command{
+	"ls":     {"List the names and domains of all lists.", listLs},
+	"create": {"Create a list.", listCreate},
+	"update": {"Update the fields of a list given as flags.", listUpdate},
+	"delete": {"Delete a list and all of its subscriptions.", listDelete},
+	"dump":   {"Print a list as JSON.", listDump},
+}
+
+type listFlags struct {
+	name, description, domain, from, senderName, replyTo string
+	trackClicks, trackOpens                              bool
+	softBounceLimit, softBounceWindow                    int
+	delivery, digestWeekday, digestTimezone              string
+	digestHour                                           int
+}
+
+func (f *listFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.name, "name", "", "Name of the list.")
+	fs.StringVar(&f.description, "description", "", "Description shown on the list's index page.")
+	fs.StringVar(&f.domain, "domain", "", "Domain the list's frontend is served on.")
+	fs.StringVar(&f.from, "from", "", "Address issues are sent from.")
+	fs.StringVar(&f.senderName, "sender-name", "", "Display name issues are sent from, defaults to the list name.")
+	fs.StringVar(&f.replyTo, "reply-to", "", "Address replies are sent to.")
+	fs.BoolVar(&f.trackClicks, "track-clicks", false, "Rewrite links in issues to count clicks.")
+	fs.BoolVar(&f.trackOpens, "track-opens", false, "Add a pixel to issues to count opens.")
+	fs.IntVar(&f.softBounceLimit, "soft-bounce-limit", 0, fmt.Sprintf("Soft bounces within the window after which a subscription is suspended, 0 for %v.", list.DEFAULT_SOFT_BOUNCE_LIMIT))
+	fs.IntVar(&f.softBounceWindow, "soft-bounce-window", 0, fmt.Sprintf("Days soft bounces are counted for, 0 for %v.", list.DEFAULT_SOFT_BOUNCE_WINDOW_DAYS))
+	fs.StringVar(&f.delivery, "delivery", list.DELIVERY_IMMEDIATE, "Send new items immediately, or collect them into a daily or weekly digest, unless subscribers choose otherwise.")
+	fs.StringVar(&f.digestWeekday, "digest-weekday", "sunday", "Day weekly digests are sent on.")
+	fs.IntVar(&f.digestHour, "digest-hour", 0, "Hour of the day digests are sent at.")
+	fs.StringVar(&f.digestTimezone, "digest-timezone", "UTC", "Timezone of the digest schedule, such as Europe/London.")
+}
+
+// applyDelivery sets the delivery fields given as flags.
+func (f *listFlags) applyDelivery(fs *flag.FlagSet, lst *list.List) error {
+	if isSet(fs, "delivery") {
+		lst.Delivery = f.delivery
+	}
+	if isSet(fs, "digest-weekday") {
+		weekday, err := parseWeekday(f.digestWeekday)
+		if err != nil {
+			return err
+		}
+		lst.DigestWeekday = weekday
+	}
+	if isSet(fs, "digest-hour") {
+		lst.DigestHour = f.digestHour
+	}
+	if isSet(fs, "digest-timezone") {
+		lst.DigestTimezone = f.digestTimezone
+	}
+	return lst.ValidateDelivery()
+}
+
+func parseWeekday(name string) (time.Weekday, error) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), name) {
+			return day, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown weekday '%v'", name)
+}
+
+func listLs(args []string) error {
+	fs := newFlagSet("list ls")
+	fs.Parse(args)
+	lsts, err := storage.Lists().GetAll()
+	if err == list.ERR_LIST_NOT_FOUND {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, lst := range *lsts {
+		fmt.Printf("%v\t%v\t%v feeds\t%v\n", lst.Name, lst.Domain, len(lst.Feeds), lst.DeliveryMode())
+	}
+	return nil
+}
+
+func listCreate(args []string) error {
+	fs := newFlagSet("list create")
+	var f listFlags
+	f.register(fs)
+	var feeds stringsFlag
+	fs.Var(&feeds, "feed", "URL of a feed to send issues for, may be repeated.")
+	fs.Parse(args)
+	err := required(map[string]string{"name": f.name, "domain": f.domain, "from": f.from})
+	if err != nil {
+		return err
+	}
+
+	_, err = storage.Lists().Get(f.name)
+	if err == nil {
+		return fmt.Errorf("list '%v' already exists", f.name)
+	}
+	if err != list.ERR_LIST_NOT_FOUND {
+		return err
+	}
+
+	lst := &list.List{
+		Name:                 f.name,
+		Description:          f.description,
+		Domain:               f.domain,
+		FromAddress:          f.from,
+		SenderName:           f.senderName,
+		ReplyToAddress:       f.replyTo,
+		TrackClicks:          f.trackClicks,
+		TrackOpens:           f.trackOpens,
+		SoftBounceLimit:      f.softBounceLimit,
+		SoftBounceWindowDays: f.softBounceWindow,
+	}
+	for _, url := range feeds {
+		lst.Feeds = append(lst.Feeds, list.Feed{Url: url})
+	}
+	err = f.applyDelivery(fs, lst)
+	if err != nil {
+		return err
+	}
+	return saveList(lst)
+}
+
+func listUpdate(args []string) error {
+	fs := newFlagSet("list update")
+	var f listFlags
+	f.register(fs)
+	fs.Parse(args)
+	err := required(map[string]string{"name": f.name})
+	if err != nil {
+		return err
+	}
+
+	lst, err := storage.Lists().Get(f.name)
+	if err != nil {
+		return err
+	}
+	if isSet(fs, "description") {
+		lst.Description = f.description
+	}
+	if isSet(fs, "domain") {
+		lst.Domain = f.domain
+	}
+	if isSet(fs, "from") {
+		lst.FromAddress = f.from
+	}
+	if isSet(fs, "sender-name") {
+		lst.SenderName = f.senderName
+	}
+	if isSet(fs, "reply-to") {
+		lst.ReplyToAddress = f.replyTo
+	}
+	if isSet(fs, "track-clicks") {
+		lst.TrackClicks = f.trackClicks
+	}
+	if isSet(fs, "track-opens") {
+		lst.TrackOpens = f.trackOpens
+	}
+	if isSet(fs, "soft-bounce-limit") {
+		lst.SoftBounceLimit = f.softBounceLimit
+	}
+	if isSet(fs, "soft-bounce-window") {
+		lst.SoftBounceWindowDays = f.softBounceWindow
+	}
+	err = f.applyDelivery(fs, lst)
+	if err != nil {
+		return err
+	}
+	return saveList(lst)
+}
+
+func listDelete(args []string) error {
+	fs := newFlagSet("list delete")
+	name := fs.String("name", "", "Name of the list.")
+	fs.Parse(args)
+	err := required(map[string]string{"name": *name})
+	if err != nil {
+		return err
+	}
+
+	lst, err := storage.Lists().Get(*name)
+	if err != nil {
+		return err
+	}
+	subs, err := storage.Subscriptions().GetAllFromList(lst.Name)
+	if err != nil {
+		return err
+	}
+	for _, sub := range *subs {
+		err = storage.Subscriptions().Delete(sub)
+		if err != nil {
+			return err
+		}
+	}
+	return storage.Lists().Delete(lst.Name)
+}
+
+func listDump(args []string) error {
+	fs := newFlagSet("list dump")
+	name := fs.String("name", "", "Name of the list.")
+	withSubscribers := fs.Bool("subscribers", false, "Include the list's subscriptions.")
+	fs.Parse(args)
+	err := required(map[string]string{"name": *name})
+	if err != nil {
+		return err
+	}
+
+	lst, err := storage.Lists().Get(*name)
+	if err != nil {
+		return err
+	}
+	if !*withSubscribers {
+		return printJSON(lst)
+	}
+	subs, err := storage.Subscriptions().GetAllFromList(lst.Name)
+	if err != nil {
+		return err
+	}
+	return printJSON(struct {
+		*list.List
+		Subscriptions interface{} `json:"subscriptions"`
+	}{lst, subs})
+}
+
+// saveList validates a list's feeds, filter and template overrides before
+// saving it, so mistakes are caught here rather than when a subscriber hits
+// them.
+func saveList(lst *list.List) error {
+	err := lst.ValidateFeeds()
+	if err != nil {
+		return err
+	}
+	err = lst.Filter.Validate()
+	if err != nil {
+		return err
+	}
+	err = listtemplates.Validate(lst)
+	if err != nil {
+		return err
+	}
+	return storage.Lists().Put(lst)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+
+	"github.com/mmcdole/gofeed"
+	"gjhr.me/newsletter/data/list"
+	"gjhr.me/newsletter/data/mailevents"
+	"gjhr.me/newsletter/issue"
+	"gjhr.me/newsletter/providers/storage"
+)
+
+var mailEventCommands = map[string]command{
+	"ls":    {"List the delivery events of a message, list or issue.", mailEventsLs},
+	"stats": {"Summarise the delivery events of a list or issue.", mailEventsStats},
+}
+
+type mailEventFlags struct {
+	message, list, guid, email string
+}
+
+func (f *mailEventFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.message, "message", "", "SES message ID.")
+	fs.StringVar(&f.list, "list", "", "Name of the list.")
+	fs.StringVar(&f.guid, "guid", "", "GUID of the feed item an issue was sent for, requires -list.")
+	fs.StringVar(&f.email, "email", "", "Only include events for this recipient.")
+}
+
+// get fetches the events selected by the flags.
+func (f *mailEventFlags) get() ([]*mailevents.MailEvent, error) {
+	var events *[]*mailevents.MailEvent
+	var err error
+	switch {
+	case f.message != "":
+		events, err = storage.MailEvents().GetForMessage(f.message)
+	case f.list != "" && f.guid != "":
+		events, err = storage.MailEvents().GetForIssue(issue.ID(&list.List{Name: f.list}, &gofeed.Item{GUID: f.guid}))
+	case f.list != "":
+		events, err = storage.MailEvents().GetForList(f.list)
+	default:
+		return nil, fmt.Errorf("one of -message or -list is required")
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := []*mailevents.MailEvent{}
+	for _, e := range *events {
+		if f.email == "" || e.HasRecipient(f.email) {
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
+
+func mailEventsLs(args []string) error {
+	fs := newFlagSet("events ls")
+	var f mailEventFlags
+	f.register(fs)
+	asJSON := fs.Bool("json", false, "Print the events as JSON.")
+	fs.Parse(args)
+
+	events, err := f.get()
+	if err != nil {
+		return err
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Timestamp.Before(events[j].Timestamp) })
+	if *asJSON {
+		return printJSON(events)
+	}
+	for _, e := range events {
+		fmt.Printf("%v\t%v\t%v\t%v\t%v\n", e.Timestamp.Format("2006-01-02 15:04:05"), e.MessageID, e.Type, e.Recipients, e.Detail)
+	}
+	return nil
+}
+
+func mailEventsStats(args []string) error {
+	fs := newFlagSet("events stats")
+	var f mailEventFlags
+	f.register(fs)
+	fs.Parse(args)
+
+	events, err := f.get()
+	if err != nil {
+		return err
+	}
+	stats := mailevents.Summarize(events)
+	fmt.Printf("Messages\t%v\n", stats.Messages)
+	fmt.Printf("Delivery rate\t%.1f%%\n", stats.DeliveryRate()*100)
+	types := make([]string, 0, len(stats.Types))
+	for eventType := range stats.Types {
+		types = append(types, eventType)
+	}
+	sort.Strings(types)
+	for _, eventType := range types {
+		fmt.Printf("%v\t%v\n", eventType, stats.Types[eventType])
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+// Command newsletterctl manages lists, feeds and subscribers directly in the
+// configured storage backend.
+//
+// Usage:
+//
+//	newsletterctl [-storage backend] [-data directory] <resource> <action> [flags]
+//
+// Run with -h for the available resources and actions.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gjhr.me/newsletter/providers/config"
+	"gjhr.me/newsletter/providers/storage"
+)
+
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands = map[string]map[string]command{
+	"list":        listCommands,
+	"feed":        feedCommands,
+	"subscriber":  subscriberCommands,
+	"template":    templateCommands,
+	"clicks":      clickCommands,
+	"opens":       openCommands,
+	"events":      mailEventCommands,
+	"suppression": suppressionCommands,
+	"filter":      filterCommands,
+}
+
+func main() {
+	conf := config.Get()
+	backend := flag.String("storage", conf.StorageBackend, "Storage backend, one of 'dynamo', 'memory' or 'file'.")
+	directory := flag.String("data", conf.StorageDirectory, "Directory used by the file storage backend.")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[flag.Arg(0)][flag.Arg(1)]
+	if !ok {
+		usage()
+		os.Exit(2)
+	}
+
+	err := storage.Use(*backend, *directory)
+	if err != nil {
+		fail(err)
+	}
+	err = cmd.run(flag.Args()[2:])
+	if err != nil {
+		fail(err)
+	}
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: newsletterctl [flags] <resource> <action> [action flags]\n\nFlags:\n")
+	flag.PrintDefaults()
+	fmt.Fprintf(out, "\nCommands:\n")
+	resources := make([]string, 0, len(commands))
+	for resource := range commands {
+		resources = append(resources, resource)
+	}
+	sort.Strings(resources)
+	for _, resource := range resources {
+		actions := make([]string, 0, len(commands[resource]))
+		for action := range commands[resource] {
+			actions = append(actions, action)
+		}
+		sort.Strings(actions)
+		for _, action := range actions {
+			fmt.Fprintf(out, "  %-24v %v\n", resource+" "+action, commands[resource][action].usage)
+		}
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	os.Exit(1)
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet(name, flag.ExitOnError)
+}
+
+// isSet reports whether a flag was explicitly given on the command line.
+func isSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+func required(values map[string]string) error {
+	missing := []string{}
+	for name, value := range values {
+		if value == "" {
+			missing = append(missing, "-"+name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("missing required flags: %v", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// stringsFlag collects every occurrence of a repeated flag.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mmcdole/gofeed"
+	"gjhr.me/newsletter/data/opens"
+	"gjhr.me/newsletter/issue"
+	"gjhr.me/newsletter/providers/storage"
+)
+
+var openCommands = map[string]command{
+	"ls": {"Summarise the opens of an issue.", opensLs},
+}
+
+func opensLs(args []string) error {
+	fs := newFlagSet("opens ls")
+	listName := fs.String("list", "", "Name of the list.")
+	guid := fs.String("guid", "", "GUID of the feed item the issue was sent for.")
+	asJSON := fs.Bool("json", false, "Print the opens of each subscriber hash as JSON.")
+	fs.Parse(args)
+	err := required(map[string]string{"list": *listName, "guid": *guid})
+	if err != nil {
+		return err
+	}
+
+	lst, err := storage.Lists().Get(*listName)
+	if err != nil {
+		return err
+	}
+	subscriberOpens, err := storage.Opens().GetForIssue(issue.ID(lst, &gofeed.Item{GUID: *guid}))
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(subscriberOpens)
+	}
+	stats := opens.Summarize(*subscriberOpens)
+	fmt.Printf("%v unique\t%v human\t%v machine\t%v total\n", stats.Unique, stats.Human, stats.Unique-stats.Human, stats.Total)
+	return nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gjhr.me/newsletter/data/list"
+	"gjhr.me/newsletter/data/subscription"
+	"gjhr.me/newsletter/providers/storage"
+)
+
+var subscriberCommands = map[string]command{
+	"ls":         {"List the subscriptions to a list.", subscriberLs},
+	"add":        {"Subscribe an address to a list without sending a verification email.", subscriberAdd},
+	"remove":     {"Remove a subscription.", subscriberRemove},
+	"verify":     {"Mark a subscription as verified.", subscriberVerify},
+	"reactivate": {"Lift the suspension of a subscription after soft bounces.", subscriberReactivate},
+}
+
+func subscriberLs(args []string) error {
+	fs := newFlagSet("subscriber ls")
+	listName := fs.String("list", "", "Name of the list.")
+	verifiedOnly := fs.Bool("verified", false, "Only list verified subscriptions.")
+	fs.Parse(args)
+	err := required(map[string]string{"list": *listName})
+	if err != nil {
+		return err
+	}
+
+	var subs *[]*subscription.Subscription
+	if *verifiedOnly {
+		subs, err = storage.Subscriptions().GetAllVerifiedFromList(*listName)
+	} else {
+		subs, err = storage.Subscriptions().GetAllFromList(*listName)
+	}
+	if err != nil {
+		return err
+	}
+	for _, sub := range *subs {
+		status := "unverified"
+		if sub.Verified != "" {
+			status = "verified"
+		}
+		if sub.Suspended {
+			status += ",suspended"
+		}
+		if sub.IsPaused(time.Now()) {
+			status += ",paused"
+		}
+		delivery := sub.Delivery
+		if delivery == "" {
+			delivery = "default"
+		}
+		fmt.Printf("%v\t%v\t%v\n", sub.Email, status, delivery)
+	}
+	return nil
+}
+
+func subscriberAdd(args []string) error {
+	fs := newFlagSet("subscriber add")
+	listName := fs.String("list", "", "Name of the list.")
+	email := fs.String("email", "", "Address to subscribe.")
+	verified := fs.Bool("verified", false, "Mark the subscription as verified.")
+	delivery := fs.String("delivery", "", "Send new items immediately, or in a daily or weekly digest, instead of as the list does.")
+	var feeds stringsFlag
+	fs.Var(&feeds, "feed", "Slug, or URL if it has none, of a feed to send, may be repeated. Defaults to every feed.")
+	fs.Parse(args)
+	err := required(map[string]string{"list": *listName, "email": *email})
+	if err != nil {
+		return err
+	}
+	if *delivery != "" && !list.IsValidDelivery(*delivery) {
+		return list.ERR_INVALID_DELIVERY
+	}
+
+	lst, err := storage.Lists().Get(*listName)
+	if err != nil {
+		return err
+	}
+	for _, key := range feeds {
+		if !lst.HasFeed(key) {
+			return fmt.Errorf("list '%v' has no feed '%v'", lst.Name, key)
+		}
+	}
+	_, err = storage.Subscriptions().Get(lst.Name, *email)
+	if err == nil {
+		return fmt.Errorf("'%v' is already subscribed to '%v'", *email, lst.Name)
+	}
+	token, err := uuid.NewUUID()
+	if err != nil {
+		return err
+	}
+	sub := &subscription.Subscription{
+		Email:             *email,
+		List:              lst.Name,
+		VerificationToken: token.String(),
+		Delivery:          *delivery,
+		Feeds:             feeds,
+		Subscribed:        time.Now(),
+	}
+	if *verified {
+		sub.Verified = "true"
+	}
+	err = storage.Subscriptions().Put(sub)
+	if err != nil {
+		return err
+	}
+	if !*verified {
+		fmt.Println(lst.FormatVerificationLink(*sub))
+	}
+	return nil
+}
+
+func subscriberRemove(args []string) error {
+	sub, err := getSubscription("subscriber remove", args)
+	if err != nil {
+		return err
+	}
+	return storage.Subscriptions().Delete(sub)
+}
+
+func subscriberVerify(args []string) error {
+	sub, err := getSubscription("subscriber verify", args)
+	if err != nil {
+		return err
+	}
+	return storage.Subscriptions().Verify(sub)
+}
+
+func subscriberReactivate(args []string) error {
+	sub, err := getSubscription("subscriber reactivate", args)
+	if err != nil {
+		return err
+	}
+	return storage.Subscriptions().Reactivate(sub)
+}
+
+func getSubscription(name string, args []string) (*subscription.Subscription, error) {
+	fs := newFlagSet(name)
+	listName := fs.String("list", "", "Name of the list.")
+	email := fs.String("email", "", "Subscribed address.")
+	fs.Parse(args)
+	err := required(map[string]string{"list": *listName, "email": *email})
+	if err != nil {
+		return nil, err
+	}
+	return storage.Subscriptions().Get(*listName, *email)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"gjhr.me/newsletter/data/suppression"
+	"gjhr.me/newsletter/providers/storage"
+)
+
+var suppressionCommands = map[string]command{
+	"ls":     {"List suppressed address hashes, or check an address with -email.", suppressionLs},
+	"add":    {"Stop all mail to an address.", suppressionAdd},
+	"remove": {"Allow mail to a suppressed address again.", suppressionRemove},
+}
+
+func suppressionLs(args []string) error {
+	fs := newFlagSet("suppression ls")
+	email := fs.String("email", "", "Only show the suppression of this address.")
+	fs.Parse(args)
+
+	var suppressions []*suppression.Suppression
+	if *email != "" {
+		s, err := storage.Suppressions().Get(suppression.Hash(*email))
+		if err != nil {
+			return err
+		}
+		suppressions = append(suppressions, s)
+	} else {
+		all, err := storage.Suppressions().GetAll()
+		if err != nil {
+			return err
+		}
+		suppressions = *all
+	}
+	for _, s := range suppressions {
+		fmt.Printf("%v\t%v\t%v\t%v\t%v\n", s.EmailHash, s.Reason, s.Timestamp.Format("2006-01-02 15:04:05"), s.MessageID, s.Diagnostic)
+	}
+	return nil
+}
+
+func suppressionAdd(args []string) error {
+	fs := newFlagSet("suppression add")
+	email := fs.String("email", "", "Address to suppress.")
+	fs.Parse(args)
+	err := required(map[string]string{"email": *email})
+	if err != nil {
+		return err
+	}
+	return storage.Suppressions().Put(suppression.New(*email, suppression.REASON_MANUAL, "", time.Now()))
+}
+
+func suppressionRemove(args []string) error {
+	fs := newFlagSet("suppression remove")
+	email := fs.String("email", "", "Suppressed address.")
+	hash := fs.String("hash", "", "Hash of the suppressed address, as shown by ls.")
+	fs.Parse(args)
+	if *hash == "" && *email != "" {
+		*hash = suppression.Hash(*email)
+	}
+	err := required(map[string]string{"email or -hash": *hash})
+	if err != nil {
+		return err
+	}
+	_, err = storage.Suppressions().Get(*hash)
+	if err != nil {
+		return err
+	}
+	return storage.Suppressions().Delete(*hash)
+}
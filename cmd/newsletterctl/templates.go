@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"gjhr.me/newsletter/listtemplates"
+	"gjhr.me/newsletter/providers/storage"
+)
+
+var templateCommands = map[string]command{
+	"ls":    {"List the templates of a list and whether they are overridden.", templateLs},
+	"set":   {"Upload a template override for a list.", templateSet},
+	"unset": {"Remove a template override from a list.", templateUnset},
+}
+
+func templateLs(args []string) error {
+	fs := newFlagSet("template ls")
+	listName := fs.String("list", "", "Name of the list.")
+	fs.Parse(args)
+	err := required(map[string]string{"list": *listName})
+	if err != nil {
+		return err
+	}
+	lst, err := storage.Lists().Get(*listName)
+	if err != nil {
+		return err
+	}
+	for _, name := range listtemplates.Names() {
+		key, ok := lst.TemplateOverrides[name]
+		if !ok {
+			key = "(default)"
+		}
+		fmt.Printf("%v\t%v\n", name, key)
+	}
+	return nil
+}
+
+func templateSet(args []string) error {
+	fs := newFlagSet("template set")
+	listName := fs.String("list", "", "Name of the list.")
+	name := fs.String("name", "", "Name of the template to override.")
+	file := fs.String("file", "", "Path of the template source.")
+	fs.Parse(args)
+	err := required(map[string]string{"list": *listName, "name": *name, "file": *file})
+	if err != nil {
+		return err
+	}
+	lst, err := storage.Lists().Get(*listName)
+	if err != nil {
+		return err
+	}
+	body, err := os.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+
+	// Keys are content addressed so a rejected upload never replaces the
+	// template currently in use.
+	sum := sha1.Sum(body)
+	key := fmt.Sprintf("lists/%v/%v-%v.html", lst.Name, *name, hex.EncodeToString(sum[:]))
+	err = storage.Templates().Put(key, string(body))
+	if err != nil {
+		return err
+	}
+	if lst.TemplateOverrides == nil {
+		lst.TemplateOverrides = map[string]string{}
+	}
+	lst.TemplateOverrides[*name] = key
+	return saveList(lst)
+}
+
+func templateUnset(args []string) error {
+	fs := newFlagSet("template unset")
+	listName := fs.String("list", "", "Name of the list.")
+	name := fs.String("name", "", "Name of the overridden template.")
+	fs.Parse(args)
+	err := required(map[string]string{"list": *listName, "name": *name})
+	if err != nil {
+		return err
+	}
+	lst, err := storage.Lists().Get(*listName)
+	if err != nil {
+		return err
+	}
+	delete(lst.TemplateOverrides, *name)
+	return saveList(lst)
+}
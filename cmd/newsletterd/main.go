@@ -0,0 +1,143 @@
+// Command newsletterd runs the whole newsletter pipeline in a single process
+// without AWS: the frontend is served over plain HTTP, feeds are polled on a
+// ticker and queued mail is handed straight to the sender.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/aws/aws-lambda-go/events"
+	"gjhr.me/newsletter/data/list"
+	"gjhr.me/newsletter/emailsender"
+	"gjhr.me/newsletter/feedreader"
+	"gjhr.me/newsletter/frontend"
+	"gjhr.me/newsletter/mailqueue"
+	"gjhr.me/newsletter/providers/config"
+	"gjhr.me/newsletter/providers/signing"
+	"gjhr.me/newsletter/providers/storage"
+	"gjhr.me/newsletter/sender"
+	"gjhr.me/newsletter/utils/jsonfile"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:8080", "Address to serve the frontend on.")
+	interval := flag.Duration("interval", 5*time.Minute, "How often feeds are polled.")
+	backend := flag.String("storage", "memory", "Storage backend, one of 'memory' or 'file'.")
+	directory := flag.String("data", ".newsletter", "Directory used by the file storage backend.")
+	listsPath := flag.String("lists", "", "Optional JSON file of lists to load on start up.")
+	transport := flag.String("transport", "spool", "Mail transport, one of 'spool' or 'smtp'.")
+	spool := flag.String("spool", "spool", "Directory mail is written to by the spool transport.")
+	smtp := flag.String("smtp", "localhost:2500", "Address of the SMTP server used by the smtp transport.")
+	scheme := flag.String("scheme", "http", "Scheme used when formatting links to the frontend.")
+	ephemeralKey := flag.Bool("ephemeral-signing-key", false, "Sign links with a random key if NEWSLETTER_SIGNING_KEYS is not set. Links stop working on restart.")
+	flag.Parse()
+
+	conf := config.Get()
+	conf.BaseUrlScheme = *scheme
+	config.Set(conf)
+	if conf.LogLevel != "" {
+		log.SetLevelFromString(conf.LogLevel)
+	}
+
+	if *ephemeralKey {
+		err := signing.UseEphemeralKey()
+		if err != nil {
+			log.WithError(err).Fatal("Failed to generate signing key")
+		}
+	}
+	err := signing.Check()
+	if err != nil {
+		log.WithError(err).Fatal("Cannot sign links")
+	}
+
+	err = storage.Use(*backend, *directory)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to set up storage")
+	}
+	if *listsPath != "" {
+		err = loadLists(*listsPath)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to load lists")
+		}
+	}
+
+	switch *transport {
+	case "spool":
+		emailsender.SetTransport(emailsender.NewSpoolTransport(*spool))
+	case "smtp":
+		emailsender.SetTransport(emailsender.NewSMTPTransport(*smtp, conf.SmtpUsername, conf.SmtpPassword))
+	default:
+		log.Fatalf("Unknown mail transport '%v'", *transport)
+	}
+
+	queue := mailqueue.NewMemoryQueue(100)
+	feedreader.SetQueue(queue)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	go send(ctx, queue)
+	go poll(ctx, *interval)
+
+	server := &http.Server{Addr: *addr, Handler: frontend.HTTPHandler()}
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+	log.Infof("Serving frontend on http://%v", *addr)
+	err = server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.WithError(err).Fatal("Frontend server failed")
+	}
+}
+
+func loadLists(path string) error {
+	var lsts []*list.List
+	err := jsonfile.Load(path, &lsts)
+	if err != nil {
+		return err
+	}
+	for _, lst := range lsts {
+		log.Infof("Loading list '%v' for domain '%v'", lst.Name, lst.Domain)
+		err = storage.Lists().Put(lst)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func poll(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := feedreader.Handle(ctx, events.CloudWatchEvent{Time: time.Now()})
+		if err != nil {
+			log.WithError(err).Error("Feed reader failed")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func send(ctx context.Context, queue *mailqueue.MemoryQueue) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case m := <-queue.Receive():
+			// Failures are logged by the sender, there is no dead letter queue to retry from.
+			sender.Send(m, log.WithField("to", m.To))
+		}
+	}
+}
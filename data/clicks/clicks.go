@@ -0,0 +1,21 @@
+package clicks
+
+import (
+	"time"
+)
+
+// LinkClicks aggregates the clicks on one link of an issue.
+type LinkClicks struct {
+	Issue  string `dynamo:"issue,hash" json:"issue"`
+	Link   string `dynamo:"link,range" json:"link"`
+	Clicks int    `dynamo:"clicks" json:"clicks"`
+	// Hashes of the subscribers who clicked, see subscription.Subscription.Hash
+	Subscribers []string  `dynamo:"subscribers,set,omitempty" json:"subscribers"`
+	LastClicked time.Time `dynamo:"last_clicked,unixtime" json:"last_clicked"`
+}
+
+// ClickStore records clicks on tracked links in issues.
+type ClickStore interface {
+	Record(issue, link, subscriber string, at time.Time) error
+	GetForIssue(issue string) (*[]*LinkClicks, error)
+}
@@ -0,0 +1,34 @@
+package clicks
+
+import (
+	"time"
+
+	"github.com/guregu/dynamo"
+)
+
+// DynamoClickStore is a ClickStore backed by a DynamoDB table keyed on issue
+// and link.
+type DynamoClickStore struct {
+	table dynamo.Table
+}
+
+func NewDynamoClickStore(table dynamo.Table) *DynamoClickStore {
+	return &DynamoClickStore{table: table}
+}
+
+func (s *DynamoClickStore) Record(issue, link, subscriber string, at time.Time) error {
+	return s.table.Update("issue", issue).Range("link", link).
+		Add("clicks", 1).
+		AddStringsToSet("subscribers", subscriber).
+		Set("last_clicked", at.Unix()).
+		Run()
+}
+
+func (s *DynamoClickStore) GetForIssue(issue string) (*[]*LinkClicks, error) {
+	var links []*LinkClicks
+	err := s.table.Get("issue", issue).All(&links)
+	if err != nil {
+		return nil, err
+	}
+	return &links, nil
+}
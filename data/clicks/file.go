@@ -0,0 +1,42 @@
+package clicks
+
+import (
+	"sync"
+	"time"
+
+	"gjhr.me/newsletter/utils/jsonfile"
+)
+
+// FileClickStore is a MemoryClickStore which writes every change through to a
+// JSON file, for local runs that should survive restarts.
+type FileClickStore struct {
+	*MemoryClickStore
+	path   string
+	saveMu sync.Mutex
+}
+
+func NewFileClickStore(path string) (*FileClickStore, error) {
+	s := &FileClickStore{MemoryClickStore: NewMemoryClickStore(), path: path}
+	var links []*LinkClicks
+	err := jsonfile.Load(path, &links)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range links {
+		s.clicks[memoryKey{c.Issue, c.Link}] = *c
+	}
+	return s, nil
+}
+
+func (s *FileClickStore) Record(issue, link, subscriber string, at time.Time) error {
+	return s.save(s.MemoryClickStore.Record(issue, link, subscriber, at))
+}
+
+func (s *FileClickStore) save(err error) error {
+	if err != nil {
+		return err
+	}
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	return jsonfile.Save(s.path, s.filter(func(*LinkClicks) bool { return true }))
+}
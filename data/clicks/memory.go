@@ -0,0 +1,66 @@
+package clicks
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+type memoryKey struct {
+	issue string
+	link  string
+}
+
+// MemoryClickStore is a thread safe, in process ClickStore.
+type MemoryClickStore struct {
+	mu     sync.RWMutex
+	clicks map[memoryKey]LinkClicks
+}
+
+func NewMemoryClickStore() *MemoryClickStore {
+	return &MemoryClickStore{clicks: map[memoryKey]LinkClicks{}}
+}
+
+func (s *MemoryClickStore) Record(issue, link, subscriber string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := memoryKey{issue, link}
+	stored, ok := s.clicks[key]
+	if !ok {
+		stored = LinkClicks{Issue: issue, Link: link}
+	}
+	stored.Clicks++
+	if !slices.Contains(stored.Subscribers, subscriber) {
+		stored.Subscribers = append(slices.Clone(stored.Subscribers), subscriber)
+	}
+	stored.LastClicked = at
+	s.clicks[key] = stored
+	return nil
+}
+
+func (s *MemoryClickStore) GetForIssue(issue string) (*[]*LinkClicks, error) {
+	links := s.filter(func(c *LinkClicks) bool { return c.Issue == issue })
+	return &links, nil
+}
+
+func (s *MemoryClickStore) filter(f func(*LinkClicks) bool) []*LinkClicks {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	links := []*LinkClicks{}
+	for _, c := range s.clicks {
+		c := c
+		c.Subscribers = slices.Clone(c.Subscribers)
+		if f(&c) {
+			links = append(links, &c)
+		}
+	}
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].Issue != links[j].Issue {
+			return links[i].Issue < links[j].Issue
+		}
+		return links[i].Link < links[j].Link
+	})
+	return links
+}
@@ -0,0 +1,81 @@
+package list
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/guregu/dynamo"
+)
+
+// DynamoListStore is a ListStore backed by a DynamoDB table keyed on list name
+// with a "domain" global secondary index.
+type DynamoListStore struct {
+	table dynamo.Table
+}
+
+func NewDynamoListStore(table dynamo.Table) *DynamoListStore {
+	return &DynamoListStore{table: table}
+}
+
+func (s *DynamoListStore) Get(name string) (*List, error) {
+	var lst List
+	err := s.table.Get("name", name).One(&lst)
+	if err == dynamo.ErrNotFound {
+		return nil, ERR_LIST_NOT_FOUND
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &lst, nil
+}
+
+func (s *DynamoListStore) GetFromDomain(domain string) (*List, error) {
+	var lsts []*List
+	err := s.table.Scan().Index("domain").Filter("'domain' = ?", domain).All(&lsts)
+	if err != nil {
+		return nil, err
+	}
+	if len(lsts) == 0 {
+		return nil, ERR_LIST_NOT_FOUND
+	}
+	if len(lsts) > 1 {
+		return nil, ERR_LIST_DOMAIN_DUPLICATED
+	}
+	return lsts[0], nil
+}
+
+func (s *DynamoListStore) GetAll() (*[]*List, error) {
+	var lsts []*List
+	err := s.table.Scan().All(&lsts)
+	if err != nil {
+		return nil, err
+	}
+	if len(lsts) == 0 {
+		return nil, ERR_LIST_NOT_FOUND
+	}
+	return &lsts, nil
+}
+
+func (s *DynamoListStore) Put(lst *List) error {
+	return s.table.Put(lst).Run()
+}
+
+func (s *DynamoListStore) Delete(name string) error {
+	return s.table.Delete("name", name).Run()
+}
+
+func (s *DynamoListStore) UpdateLastDigest(lst *List, delivery string, at time.Time) error {
+	return s.update(lst).Set(fmt.Sprintf("last_%v_digest", delivery), at.Unix()).Run()
+}
+
+func (s *DynamoListStore) update(lst *List) *dynamo.Update {
+	return s.table.Update("name", lst.Name)
+}
+
+func (s *DynamoListStore) UpdateFeedLastUpdated(lst *List, feedIndex int) error {
+	return s.update(lst).Set(fmt.Sprintf("feeds[%v].last_updated", feedIndex), time.Now().Unix()).Run()
+}
+
+func (s *DynamoListStore) UpdateProcessedGuids(lst *List, feedIndex int, guid string) error {
+	return s.update(lst).Append(fmt.Sprintf("feeds[%v].processed_guids", feedIndex), []string{guid}).Run()
+}
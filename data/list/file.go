@@ -0,0 +1,65 @@
+package list
+
+import (
+	"sync"
+	"time"
+
+	"gjhr.me/newsletter/utils/jsonfile"
+)
+
+// FileListStore is a MemoryListStore which writes every change through to a
+// JSON file, for local runs that should survive restarts.
+type FileListStore struct {
+	*MemoryListStore
+	path   string
+	saveMu sync.Mutex
+}
+
+func NewFileListStore(path string) (*FileListStore, error) {
+	s := &FileListStore{MemoryListStore: NewMemoryListStore(), path: path}
+	var lsts []*List
+	err := jsonfile.Load(path, &lsts)
+	if err != nil {
+		return nil, err
+	}
+	for _, lst := range lsts {
+		s.MemoryListStore.Put(lst)
+	}
+	return s, nil
+}
+
+func (s *FileListStore) Put(lst *List) error {
+	return s.save(s.MemoryListStore.Put(lst))
+}
+
+func (s *FileListStore) Delete(name string) error {
+	return s.save(s.MemoryListStore.Delete(name))
+}
+
+func (s *FileListStore) UpdateFeedLastUpdated(lst *List, feedIndex int) error {
+	return s.save(s.MemoryListStore.UpdateFeedLastUpdated(lst, feedIndex))
+}
+
+func (s *FileListStore) UpdateProcessedGuids(lst *List, feedIndex int, guid string) error {
+	return s.save(s.MemoryListStore.UpdateProcessedGuids(lst, feedIndex, guid))
+}
+
+func (s *FileListStore) UpdateLastDigest(lst *List, delivery string, at time.Time) error {
+	return s.save(s.MemoryListStore.UpdateLastDigest(lst, delivery, at))
+}
+
+func (s *FileListStore) save(err error) error {
+	if err != nil {
+		return err
+	}
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	lsts, err := s.MemoryListStore.GetAll()
+	if err == ERR_LIST_NOT_FOUND {
+		return jsonfile.Save(s.path, []*List{})
+	}
+	if err != nil {
+		return err
+	}
+	return jsonfile.Save(s.path, lsts)
+}
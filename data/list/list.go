@@ -1,98 +1,328 @@
 package list
 
 import (
+	"crypto/subtle"
+	"encoding/json"
 	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"regexp"
+	"strings"
 	"time"
 
-	"github.com/guregu/dynamo"
 	"gjhr.me/newsletter/data/subscription"
-	"gjhr.me/newsletter/providers/aws"
+	"gjhr.me/newsletter/itemfilter"
 	"gjhr.me/newsletter/providers/config"
+	"gjhr.me/newsletter/providers/signing"
 	"gjhr.me/newsletter/utils/consterror"
 )
 
 const (
 	ERR_LIST_NOT_FOUND         = consterror.ConstError("List not found")
 	ERR_LIST_DOMAIN_DUPLICATED = consterror.ConstError("Multiple lists found for domain")
+	ERR_FEED_NOT_FOUND         = consterror.ConstError("Feed not found")
+	ERR_INVALID_DELIVERY       = consterror.ConstError("Delivery must be immediate, daily or weekly")
+	ERR_INVALID_DIGEST_TIME    = consterror.ConstError("Digest hour must be 0-23 and weekday 0-6, Sunday first")
+	ERR_INVALID_FEED_SLUG      = consterror.ConstError("Feed slugs must be lowercase letters, digits and dashes")
+	ERR_FEED_DUPLICATED        = consterror.ConstError("Feeds must have distinct URLs and slugs")
 )
 
-var table dynamo.Table
+// How new feed items are sent to subscribers.
+const (
+	DELIVERY_IMMEDIATE = "immediate"
+	DELIVERY_DAILY     = "daily"
+	DELIVERY_WEEKLY    = "weekly"
+)
 
-func init() {
-	table = aws.Dynamo().Table(config.Get().ListsTable)
-}
+// Soft bounce thresholds used when a list does not set its own.
+const (
+	DEFAULT_SOFT_BOUNCE_LIMIT       = 3
+	DEFAULT_SOFT_BOUNCE_WINDOW_DAYS = 7
+)
+
+// Purposes signed into tokens of subscriber links.
+const (
+	TOKEN_PURPOSE_UNSUBSCRIBE = "unsubscribe"
+	TOKEN_PURPOSE_PREFERENCES = "preferences"
+)
 
 type List struct {
 	Name           string `dynamo:"name" json:"name"`
 	Description    string `dynamo:"description" json:"description"`
 	Domain         string `dynamo:"domain" json:"domain"`
 	FromAddress    string `dynamo:"from_address" json:"from_address"`
+	SenderName     string `dynamo:"sender_name,omitempty" json:"sender_name,omitempty"`
 	ReplyToAddress string `dynamo:"reply_to_address" json:"reply_to_address"`
 	Feeds          []Feed `dynamo:"feeds" json:"feeds"`
+	// Template name to template store key of page and email template overrides
+	TemplateOverrides map[string]string `dynamo:"template_overrides,omitempty" json:"template_overrides,omitempty"`
+	// Whether links in issues are rewritten to count clicks
+	TrackClicks bool `dynamo:"track_clicks,omitempty" json:"track_clicks,omitempty"`
+	// Whether issues include a pixel to count opens
+	TrackOpens bool `dynamo:"track_opens,omitempty" json:"track_opens,omitempty"`
+	// Subscriptions are suspended after this many transient bounces within
+	// the window, zero for the defaults
+	SoftBounceLimit      int `dynamo:"soft_bounce_limit,omitempty" json:"soft_bounce_limit,omitempty"`
+	SoftBounceWindowDays int `dynamo:"soft_bounce_window_days,omitempty" json:"soft_bounce_window_days,omitempty"`
+	// Whether items are sent as they are published or collected into a
+	// digest for subscribers who have not chosen, empty for immediate
+	Delivery string `dynamo:"delivery,omitempty" json:"delivery,omitempty"`
+	// When digests are sent, the weekday only applies to weekly digests
+	DigestWeekday  time.Weekday `dynamo:"digest_weekday,omitempty" json:"digest_weekday,omitempty"`
+	DigestHour     int          `dynamo:"digest_hour,omitempty" json:"digest_hour,omitempty"`
+	DigestTimezone string       `dynamo:"digest_timezone,omitempty" json:"digest_timezone,omitempty"`
+	// When the last digest of each kind was sent, or its schedule started
+	LastDailyDigest  time.Time `dynamo:"last_daily_digest,unixtime,omitempty" json:"last_daily_digest,omitempty"`
+	LastWeeklyDigest time.Time `dynamo:"last_weekly_digest,unixtime,omitempty" json:"last_weekly_digest,omitempty"`
+	// Which items of the list's feeds are sent at all, nil for every item
+	Filter *itemfilter.Filter `dynamo:"filter,omitempty" json:"filter,omitempty"`
 }
 
 type Feed struct {
-	Url            string    `dynamo:"url" json:"url"`
-	LastUpdated    time.Time `dynamo:"last_updated,unixtime" json:"-"`
+	Url string `dynamo:"url" json:"url"`
+	// Name shown to subscribers choosing feeds
+	Name string `dynamo:"name,omitempty" json:"name,omitempty"`
+	// Identifies the feed in subscribers' choices and links, see Key
+	Slug           string    `dynamo:"slug,omitempty" json:"slug,omitempty"`
+	LastUpdated    time.Time `dynamo:"last_updated,unixtime" json:"last_updated"`
 	ProcessedGuids []string  `dynamo:"processed_guids" json:"processed_guids"`
 }
 
-func (lst *List) FormatBaseURL() string {
-	return fmt.Sprintf("https://%v", lst.Domain)
+// ListStore persists lists and their feed processing state.
+type ListStore interface {
+	Get(name string) (*List, error)
+	GetFromDomain(domain string) (*List, error)
+	GetAll() (*[]*List, error)
+	Put(lst *List) error
+	Delete(name string) error
+	UpdateFeedLastUpdated(lst *List, feedIndex int) error
+	UpdateProcessedGuids(lst *List, feedIndex int, guid string) error
+	// UpdateLastDigest records when a daily or weekly digest was sent.
+	UpdateLastDigest(lst *List, delivery string, at time.Time) error
 }
 
-func (lst *List) FormatUnsubscribeLink(sub subscription.Subscription) string {
-	return fmt.Sprintf("%v/unsubscribe?email=%v", lst.FormatBaseURL(), sub.Email)
+var feedSlugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Key identifies the feed in subscribers' choices of feeds, which is its slug
+// or failing that its URL.
+func (f Feed) Key() string {
+	if f.Slug != "" {
+		return f.Slug
+	}
+	return f.Url
 }
 
-func (lst *List) FormatVerificationLink(sub subscription.Subscription) string {
-	return fmt.Sprintf("%v/verify?token=%v", lst.FormatBaseURL(), sub.VerificationToken)
+// DisplayName is the name of the feed, falling back to its slug or URL.
+func (f Feed) DisplayName() string {
+	if f.Name != "" {
+		return f.Name
+	}
+	return f.Key()
+}
+
+// FeedKey returns the key of the list's feed with the given URL.
+func (lst *List) FeedKey(url string) string {
+	for _, feed := range lst.Feeds {
+		if feed.Url == url {
+			return feed.Key()
+		}
+	}
+	return url
+}
+
+// HasFeed reports whether the list has a feed with the given key.
+func (lst *List) HasFeed(key string) bool {
+	for _, feed := range lst.Feeds {
+		if feed.Key() == key {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateFeeds checks feed slugs are well formed and that no two feeds can
+// be mistaken for each other.
+func (lst *List) ValidateFeeds() error {
+	seen := map[string]bool{}
+	for _, feed := range lst.Feeds {
+		if feed.Slug != "" && !feedSlugPattern.MatchString(feed.Slug) {
+			return ERR_INVALID_FEED_SLUG
+		}
+		if seen[feed.Url] || seen[feed.Key()] {
+			return ERR_FEED_DUPLICATED
+		}
+		seen[feed.Url] = true
+		seen[feed.Key()] = true
+	}
+	return nil
+}
+
+// DigestDeliveries are the delivery modes which collect items into digests.
+var DigestDeliveries = []string{DELIVERY_DAILY, DELIVERY_WEEKLY}
+
+// IsValidDelivery reports whether a delivery mode is known.
+func IsValidDelivery(delivery string) bool {
+	return delivery == DELIVERY_IMMEDIATE || delivery == DELIVERY_DAILY || delivery == DELIVERY_WEEKLY
+}
+
+// DeliveryMode returns how new items of the list are sent.
+func (lst *List) DeliveryMode() string {
+	if lst.Delivery == "" {
+		return DELIVERY_IMMEDIATE
+	}
+	return lst.Delivery
+}
+
+// DeliveryFor returns how new items are sent to a subscriber, which is their
+// own choice if they have made one.
+func (lst *List) DeliveryFor(sub *subscription.Subscription) string {
+	if IsValidDelivery(sub.Delivery) {
+		return sub.Delivery
+	}
+	return lst.DeliveryMode()
+}
+
+// LastDigest returns when the last digest of the given kind was sent.
+func (lst *List) LastDigest(delivery string) time.Time {
+	if delivery == DELIVERY_WEEKLY {
+		return lst.LastWeeklyDigest
+	}
+	return lst.LastDailyDigest
 }
 
-func Get(name string) (*List, error) {
-	var lst List
-	err := table.Get("name", name).One(&lst)
+// ValidateDelivery checks the delivery mode and digest schedule.
+func (lst *List) ValidateDelivery() error {
+	if !IsValidDelivery(lst.DeliveryMode()) {
+		return ERR_INVALID_DELIVERY
+	}
+	if lst.DigestHour < 0 || lst.DigestHour > 23 || lst.DigestWeekday < time.Sunday || lst.DigestWeekday > time.Saturday {
+		return ERR_INVALID_DIGEST_TIME
+	}
+	_, err := time.LoadLocation(lst.DigestTimezone)
+	return err
+}
+
+// LastScheduledDigest returns the latest time a daily or weekly digest of the
+// list was scheduled for at or before now. An empty timezone is UTC.
+func (lst *List) LastScheduledDigest(delivery string, now time.Time) (time.Time, error) {
+	loc, err := time.LoadLocation(lst.DigestTimezone)
 	if err != nil {
-		return nil, err
+		return time.Time{}, err
 	}
-	return &lst, nil
+	local := now.In(loc)
+	scheduled := time.Date(local.Year(), local.Month(), local.Day(), lst.DigestHour, 0, 0, 0, loc)
+	for scheduled.After(now) || (delivery == DELIVERY_WEEKLY && scheduled.Weekday() != lst.DigestWeekday) {
+		day := scheduled.AddDate(0, 0, -1)
+		scheduled = time.Date(day.Year(), day.Month(), day.Day(), lst.DigestHour, 0, 0, 0, loc)
+	}
+	return scheduled, nil
 }
 
-func GetFromDomain(domain string) (*List, error) {
-	var lsts []*List
-	err := table.Scan().Index("domain").Filter("'domain' = ?", domain).All(&lsts)
+// DigestDue reports whether a daily or weekly digest has been scheduled since
+// the last one was sent.
+func (lst *List) DigestDue(delivery string, now time.Time) (bool, error) {
+	scheduled, err := lst.LastScheduledDigest(delivery, now)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	if len(lsts) == 0 {
-		return nil, ERR_LIST_NOT_FOUND
+	return lst.LastDigest(delivery).Before(scheduled), nil
+}
+
+// SoftBounceThreshold returns the number of transient bounces within a window
+// after which a subscription to the list is suspended.
+func (lst *List) SoftBounceThreshold() (limit int, window time.Duration) {
+	limit = lst.SoftBounceLimit
+	if limit <= 0 {
+		limit = DEFAULT_SOFT_BOUNCE_LIMIT
 	}
-	if len(lsts) > 1 {
-		return nil, ERR_LIST_DOMAIN_DUPLICATED
+	days := lst.SoftBounceWindowDays
+	if days <= 0 {
+		days = DEFAULT_SOFT_BOUNCE_WINDOW_DAYS
 	}
-	return lsts[0], nil
+	return limit, time.Duration(days) * 24 * time.Hour
+}
+
+func (lst *List) FormatBaseURL() string {
+	return fmt.Sprintf("%v://%v", config.Get().BaseUrlScheme, lst.Domain)
+}
+
+// SubscriptionLink identifies the subscription an unsubscribe or preferences
+// link was sent for.
+type SubscriptionLink struct {
+	Email string `json:"e"`
+	ID    string `json:"i"`
+}
+
+// FormatUnsubscribeLink signs the address with the subscription's LinkID, so
+// links can be neither forged nor used to verify the address, and stop
+// working once it resubscribes.
+func (lst *List) FormatUnsubscribeLink(sub subscription.Subscription) string {
+	return lst.formatSubscriptionLink("unsubscribe", TOKEN_PURPOSE_UNSUBSCRIBE, sub)
 }
 
-func GetAll() (*[]*List, error) {
-	var lsts []*List
-	err := table.Scan().All(&lsts)
+// FormatPreferencesLink formats a link to the subscription's preferences,
+// signed like unsubscribe links.
+func (lst *List) FormatPreferencesLink(sub subscription.Subscription) string {
+	return lst.formatSubscriptionLink("preferences", TOKEN_PURPOSE_PREFERENCES, sub)
+}
+
+func (lst *List) formatSubscriptionLink(path, purpose string, sub subscription.Subscription) string {
+	payload, _ := json.Marshal(SubscriptionLink{Email: sub.Email, ID: sub.LinkID()})
+	token := signing.Signer().Sign(purpose, string(payload))
+	return fmt.Sprintf("%v/%v?token=%v", lst.FormatBaseURL(), path, token)
+}
+
+// ParseSubscriptionLink verifies the token of a link signed for the purpose.
+func ParseSubscriptionLink(purpose, token string) (*SubscriptionLink, error) {
+	payload, err := signing.Signer().Verify(purpose, token)
 	if err != nil {
 		return nil, err
 	}
-	if len(lsts) == 0 {
-		return nil, ERR_LIST_NOT_FOUND
+	var link SubscriptionLink
+	err = json.Unmarshal([]byte(payload), &link)
+	if err != nil {
+		return nil, err
 	}
-	return &lsts, nil
+	return &link, nil
 }
 
-func (lst *List) update() *dynamo.Update {
-	return table.Update("name", lst.Name)
+// IsFor reports whether the link was sent for the subscription, rather than
+// an earlier subscription of the same address.
+func (link *SubscriptionLink) IsFor(sub *subscription.Subscription) bool {
+	return subtle.ConstantTimeCompare([]byte(link.ID), []byte(sub.LinkID())) == 1
 }
 
-func (lst *List) UpdateFeedLastUpdated(feedIndex int) error {
-	return lst.update().Set(fmt.Sprintf("feeds[%v].last_updated", feedIndex), time.Now().Unix()).Run()
+// FormatFromAddress formats the list's from address with its sender name, or
+// the list name if none is set. Non ASCII names are RFC 2047 encoded.
+func (lst *List) FormatFromAddress() string {
+	name := lst.SenderName
+	if name == "" {
+		name = lst.Name
+	}
+	return (&mail.Address{Name: name, Address: lst.FromAddress}).String()
 }
 
-func (lst *List) UpdateProcessedGuids(feedIndex int, guid string) error {
-	return lst.update().Append(fmt.Sprintf("feeds[%v].processed_guids", feedIndex), []string{guid}).Run()
+var listIDInvalidChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// FormatListID formats an RFC 2919 List-Id header value such as
+// `"My List" <my-list.example.com>`.
+func (lst *List) FormatListID() string {
+	host := lst.Domain
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	id := strings.Trim(listIDInvalidChars.ReplaceAllString(strings.ToLower(lst.Name), "-"), "-")
+	if id == "" {
+		id = "list"
+	}
+	phrase := mime.QEncoding.Encode("UTF-8", lst.Name)
+	if phrase == lst.Name {
+		phrase = fmt.Sprintf("%q", lst.Name)
+	}
+	return fmt.Sprintf("%v <%v.%v>", phrase, id, host)
+}
+
+func (lst *List) FormatVerificationLink(sub subscription.Subscription) string {
+	return fmt.Sprintf("%v/verify?token=%v", lst.FormatBaseURL(), sub.VerificationToken)
 }
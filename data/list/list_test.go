@@ -0,0 +1,117 @@
+package list
+
+import (
+	"testing"
+	"time"
+	// Timezones used by the tests wherever the system has no database
+	_ "time/tzdata"
+)
+
+func mustParse(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func TestLastScheduledDigest(t *testing.T) {
+	tests := []struct {
+		name     string
+		list     List
+		delivery string
+		now      string
+		want     string
+	}{
+		{"utc by default", List{DigestHour: 9}, DELIVERY_DAILY, "2024-06-10T08:00:00Z", "2024-06-09T09:00:00Z"},
+		{"utc at the hour", List{DigestHour: 9}, DELIVERY_DAILY, "2024-06-10T09:00:00Z", "2024-06-10T09:00:00Z"},
+		{"summer time", List{DigestHour: 9, DigestTimezone: "Europe/London"}, DELIVERY_DAILY, "2024-06-10T08:30:00Z", "2024-06-10T08:00:00Z"},
+		{"before the hour in summer time", List{DigestHour: 9, DigestTimezone: "Europe/London"}, DELIVERY_DAILY, "2024-06-10T07:30:00Z", "2024-06-09T08:00:00Z"},
+		{"winter time", List{DigestHour: 9, DigestTimezone: "Europe/London"}, DELIVERY_DAILY, "2024-01-10T09:30:00Z", "2024-01-10T09:00:00Z"},
+		{"behind utc, earlier utc day", List{DigestHour: 20, DigestTimezone: "America/Los_Angeles"}, DELIVERY_DAILY, "2024-06-11T02:00:00Z", "2024-06-10T03:00:00Z"},
+		{"behind utc, same utc day", List{DigestHour: 20, DigestTimezone: "America/Los_Angeles"}, DELIVERY_DAILY, "2024-06-11T04:00:00Z", "2024-06-11T03:00:00Z"},
+		{"ahead of utc, later local day", List{DigestHour: 6, DigestTimezone: "Asia/Tokyo"}, DELIVERY_DAILY, "2024-06-10T22:00:00Z", "2024-06-10T21:00:00Z"},
+		{"half hour offset", List{DigestHour: 8, DigestTimezone: "Asia/Kolkata"}, DELIVERY_DAILY, "2024-06-10T03:00:00Z", "2024-06-10T02:30:00Z"},
+		{"day after spring forward", List{DigestHour: 9, DigestTimezone: "Europe/London"}, DELIVERY_DAILY, "2024-03-31T08:30:00Z", "2024-03-31T08:00:00Z"},
+		{"day before spring forward", List{DigestHour: 9, DigestTimezone: "Europe/London"}, DELIVERY_DAILY, "2024-03-31T07:30:00Z", "2024-03-30T09:00:00Z"},
+		{"day of fall back", List{DigestHour: 9, DigestTimezone: "America/New_York"}, DELIVERY_DAILY, "2024-11-03T14:30:00Z", "2024-11-03T14:00:00Z"},
+		{"weekly later in the week", List{DigestHour: 9, DigestWeekday: time.Monday, DigestTimezone: "Europe/London"}, DELIVERY_WEEKLY, "2024-06-12T12:00:00Z", "2024-06-10T08:00:00Z"},
+		{"weekly earlier on the day", List{DigestHour: 9, DigestWeekday: time.Sunday, DigestTimezone: "Europe/London"}, DELIVERY_WEEKLY, "2024-06-09T07:00:00Z", "2024-06-02T08:00:00Z"},
+		{"weekly by local weekday", List{DigestHour: 7, DigestWeekday: time.Monday, DigestTimezone: "Pacific/Auckland"}, DELIVERY_WEEKLY, "2024-06-09T20:00:00Z", "2024-06-09T19:00:00Z"},
+		{"weekly across spring forward", List{DigestHour: 9, DigestWeekday: time.Saturday, DigestTimezone: "Europe/London"}, DELIVERY_WEEKLY, "2024-04-05T12:00:00Z", "2024-03-30T09:00:00Z"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.list.LastScheduledDigest(test.delivery, mustParse(t, test.now))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := mustParse(t, test.want); !got.Equal(want) {
+				t.Errorf("got %v, want %v", got.UTC(), want)
+			}
+		})
+	}
+}
+
+func TestLastScheduledDigestUnknownTimezone(t *testing.T) {
+	l := List{DigestTimezone: "Nowhere/Special"}
+	_, err := l.LastScheduledDigest(DELIVERY_DAILY, time.Now())
+	if err == nil {
+		t.Error("expected an error for an unknown timezone")
+	}
+}
+
+// TestDigestDueOncePerPeriod runs the feed reader's check every quarter hour
+// through clock changes, including digests scheduled in the skipped and
+// repeated hours, and counts the digests sent.
+func TestDigestDueOncePerPeriod(t *testing.T) {
+	tests := []struct {
+		name     string
+		timezone string
+		hour     int
+		delivery string
+		start    string
+		days     int
+		want     int
+	}{
+		{"daily through spring forward", "Europe/London", 9, DELIVERY_DAILY, "2024-03-28T12:00:00Z", 7, 7},
+		{"daily in the skipped hour", "Europe/London", 1, DELIVERY_DAILY, "2024-03-28T12:00:00Z", 7, 7},
+		{"daily through fall back", "Europe/London", 9, DELIVERY_DAILY, "2024-10-24T12:00:00Z", 7, 7},
+		{"daily in the repeated hour", "Europe/London", 1, DELIVERY_DAILY, "2024-10-24T12:00:00Z", 7, 7},
+		{"daily in the skipped hour, new york", "America/New_York", 2, DELIVERY_DAILY, "2024-03-07T12:00:00Z", 7, 7},
+		{"daily at midnight, southern hemisphere", "Australia/Sydney", 0, DELIVERY_DAILY, "2024-04-03T12:00:00Z", 7, 7},
+		{"weekly through spring forward", "Europe/London", 1, DELIVERY_WEEKLY, "2024-03-17T12:00:00Z", 28, 4},
+		{"weekly through fall back", "America/New_York", 1, DELIVERY_WEEKLY, "2024-10-20T12:00:00Z", 28, 4},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			start := mustParse(t, test.start)
+			l := &List{DigestHour: test.hour, DigestWeekday: time.Sunday, DigestTimezone: test.timezone}
+			if test.delivery == DELIVERY_WEEKLY {
+				l.LastWeeklyDigest = start
+			} else {
+				l.LastDailyDigest = start
+			}
+			sent := 0
+			for now := start; now.Before(start.AddDate(0, 0, test.days)); now = now.Add(15 * time.Minute) {
+				due, err := l.DigestDue(test.delivery, now)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !due {
+					continue
+				}
+				sent++
+				if test.delivery == DELIVERY_WEEKLY {
+					l.LastWeeklyDigest = now
+				} else {
+					l.LastDailyDigest = now
+				}
+			}
+			if sent != test.want {
+				t.Errorf("sent %v digests, want %v", sent, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package list
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryListStore is a thread safe, in process ListStore. Lists are copied on
+// the way in and out so callers never share state with the store.
+type MemoryListStore struct {
+	mu    sync.RWMutex
+	lists map[string]*List
+}
+
+func NewMemoryListStore() *MemoryListStore {
+	return &MemoryListStore{lists: map[string]*List{}}
+}
+
+func (s *MemoryListStore) Get(name string) (*List, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	lst, ok := s.lists[name]
+	if !ok {
+		return nil, ERR_LIST_NOT_FOUND
+	}
+	return lst.clone(), nil
+}
+
+func (s *MemoryListStore) GetFromDomain(domain string) (*List, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var found *List
+	for _, lst := range s.lists {
+		if lst.Domain != domain {
+			continue
+		}
+		if found != nil {
+			return nil, ERR_LIST_DOMAIN_DUPLICATED
+		}
+		found = lst
+	}
+	if found == nil {
+		return nil, ERR_LIST_NOT_FOUND
+	}
+	return found.clone(), nil
+}
+
+func (s *MemoryListStore) GetAll() (*[]*List, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.lists) == 0 {
+		return nil, ERR_LIST_NOT_FOUND
+	}
+	lsts := make([]*List, 0, len(s.lists))
+	for _, lst := range s.lists {
+		lsts = append(lsts, lst.clone())
+	}
+	sort.Slice(lsts, func(i, j int) bool { return lsts[i].Name < lsts[j].Name })
+	return &lsts, nil
+}
+
+func (s *MemoryListStore) Put(lst *List) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lists[lst.Name] = lst.clone()
+	return nil
+}
+
+func (s *MemoryListStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.lists, name)
+	return nil
+}
+
+func (s *MemoryListStore) UpdateFeedLastUpdated(lst *List, feedIndex int) error {
+	return s.updateFeed(lst.Name, feedIndex, func(f *Feed) {
+		f.LastUpdated = time.Now()
+	})
+}
+
+func (s *MemoryListStore) UpdateProcessedGuids(lst *List, feedIndex int, guid string) error {
+	return s.updateFeed(lst.Name, feedIndex, func(f *Feed) {
+		f.ProcessedGuids = append(f.ProcessedGuids, guid)
+	})
+}
+
+func (s *MemoryListStore) UpdateLastDigest(lst *List, delivery string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.lists[lst.Name]
+	if !ok {
+		return ERR_LIST_NOT_FOUND
+	}
+	if delivery == DELIVERY_WEEKLY {
+		stored.LastWeeklyDigest = at
+	} else {
+		stored.LastDailyDigest = at
+	}
+	return nil
+}
+
+func (s *MemoryListStore) updateFeed(name string, feedIndex int, f func(*Feed)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lst, ok := s.lists[name]
+	if !ok {
+		return ERR_LIST_NOT_FOUND
+	}
+	if feedIndex < 0 || feedIndex >= len(lst.Feeds) {
+		return ERR_FEED_NOT_FOUND
+	}
+	f(&lst.Feeds[feedIndex])
+	return nil
+}
+
+func (lst *List) clone() *List {
+	c := *lst
+	if lst.TemplateOverrides != nil {
+		c.TemplateOverrides = make(map[string]string, len(lst.TemplateOverrides))
+		for name, key := range lst.TemplateOverrides {
+			c.TemplateOverrides[name] = key
+		}
+	}
+	c.Filter = lst.Filter.Copy()
+	c.Feeds = make([]Feed, len(lst.Feeds))
+	for i, feed := range lst.Feeds {
+		c.Feeds[i] = feed
+		c.Feeds[i].ProcessedGuids = append([]string(nil), feed.ProcessedGuids...)
+	}
+	return &c
+}
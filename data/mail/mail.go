@@ -10,6 +10,9 @@ type Mail struct {
 	From           string             `json:"from"`
 	ReplyTo        string             `json:"reply_to"`
 	Subject        string             `json:"subject"`
+	ListID         string             `json:"list_id"`
+	List           string             `json:"list"`
+	Issue          string             `json:"issue,omitempty"`
 	TemplateBucket string             `json:"template_bucket"`
 	TemplateKey    string             `json:"template_key"`
 	TemplateValues MailTemplateValues `json:"template_values"`
@@ -18,13 +21,16 @@ type Mail struct {
 func New(s *subscription.Subscription, l *list.List, subject string, templateBucket string, templateKey string) Mail {
 	return Mail{
 		To:             s.Email,
-		From:           l.FromAddress,
+		From:           l.FormatFromAddress(),
 		ReplyTo:        l.ReplyToAddress,
 		TemplateBucket: templateBucket,
 		TemplateKey:    templateKey,
 		Subject:        subject,
+		ListID:         l.FormatListID(),
+		List:           l.Name,
 		TemplateValues: MailTemplateValues{
 			UnsubscribeLink: l.FormatUnsubscribeLink(*s),
+			PreferencesLink: l.FormatPreferencesLink(*s),
 			ListName:        l.Name,
 			Email:           s.Email,
 		},
@@ -33,6 +39,11 @@ func New(s *subscription.Subscription, l *list.List, subject string, templateBuc
 
 type MailTemplateValues struct {
 	UnsubscribeLink string `json:"unsubscribe_link"`
+	PreferencesLink string `json:"preferences_link,omitempty"`
 	ListName        string `json:"list_name"`
 	Email           string `json:"email"`
+	// Tracked links, in the order the issue refers to them
+	ClickLinks []string `json:"click_links,omitempty"`
+	// Source of the tracking pixel
+	OpenPixel string `json:"open_pixel,omitempty"`
 }
@@ -0,0 +1,46 @@
+package mailevents
+
+import (
+	"github.com/guregu/dynamo"
+)
+
+// DynamoMailEventStore is a MailEventStore backed by a DynamoDB table keyed on
+// message ID and event key with "list" and "issue" global secondary indexes.
+type DynamoMailEventStore struct {
+	table dynamo.Table
+}
+
+func NewDynamoMailEventStore(table dynamo.Table) *DynamoMailEventStore {
+	return &DynamoMailEventStore{table: table}
+}
+
+func (s *DynamoMailEventStore) Put(e *MailEvent) error {
+	return s.table.Put(e).Run()
+}
+
+func (s *DynamoMailEventStore) GetForMessage(messageID string) (*[]*MailEvent, error) {
+	var events []*MailEvent
+	err := s.table.Get("message_id", messageID).All(&events)
+	if err != nil {
+		return nil, err
+	}
+	return &events, nil
+}
+
+func (s *DynamoMailEventStore) GetForList(list string) (*[]*MailEvent, error) {
+	var events []*MailEvent
+	err := s.table.Get("list", list).Index("list").All(&events)
+	if err != nil {
+		return nil, err
+	}
+	return &events, nil
+}
+
+func (s *DynamoMailEventStore) GetForIssue(issue string) (*[]*MailEvent, error) {
+	var events []*MailEvent
+	err := s.table.Get("issue", issue).Index("issue").All(&events)
+	if err != nil {
+		return nil, err
+	}
+	return &events, nil
+}
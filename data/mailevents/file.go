@@ -0,0 +1,41 @@
+package mailevents
+
+import (
+	"sync"
+
+	"gjhr.me/newsletter/utils/jsonfile"
+)
+
+// FileMailEventStore is a MemoryMailEventStore which writes every change
+// through to a JSON file, for local runs that should survive restarts.
+type FileMailEventStore struct {
+	*MemoryMailEventStore
+	path   string
+	saveMu sync.Mutex
+}
+
+func NewFileMailEventStore(path string) (*FileMailEventStore, error) {
+	s := &FileMailEventStore{MemoryMailEventStore: NewMemoryMailEventStore(), path: path}
+	var events []*MailEvent
+	err := jsonfile.Load(path, &events)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range events {
+		s.MemoryMailEventStore.Put(e)
+	}
+	return s, nil
+}
+
+func (s *FileMailEventStore) Put(e *MailEvent) error {
+	return s.save(s.MemoryMailEventStore.Put(e))
+}
+
+func (s *FileMailEventStore) save(err error) error {
+	if err != nil {
+		return err
+	}
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	return jsonfile.Save(s.path, s.filter(func(*MailEvent) bool { return true }))
+}
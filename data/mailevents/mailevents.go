@@ -0,0 +1,91 @@
+package mailevents
+
+import (
+	"time"
+)
+
+// Types of events, as named by SES.
+const (
+	TYPE_SEND              = "Send"
+	TYPE_REJECT            = "Reject"
+	TYPE_DELIVERY          = "Delivery"
+	TYPE_DELIVERY_DELAY    = "DeliveryDelay"
+	TYPE_BOUNCE            = "Bounce"
+	TYPE_COMPLAINT         = "Complaint"
+	TYPE_OPEN              = "Open"
+	TYPE_CLICK             = "Click"
+	TYPE_RENDERING_FAILURE = "Rendering Failure"
+)
+
+// MailEvent is a notification about a sent message, such as its delivery or
+// bounce.
+type MailEvent struct {
+	MessageID string `dynamo:"message_id,hash" json:"message_id"`
+	// Timestamp and type, to keep events of one message apart and in order
+	Key        string    `dynamo:"key,range" json:"key"`
+	Type       string    `dynamo:"type" json:"type"`
+	Timestamp  time.Time `dynamo:"timestamp" json:"timestamp"`
+	List       string    `dynamo:"list,omitempty" json:"list,omitempty"`
+	Issue      string    `dynamo:"issue,omitempty" json:"issue,omitempty"`
+	Recipients []string  `dynamo:"recipients,set,omitempty" json:"recipients"`
+	// Type specific details, such as the bounce type or clicked link
+	Detail string `dynamo:"detail,omitempty" json:"detail,omitempty"`
+}
+
+func New(messageID string, eventType string, timestamp time.Time) *MailEvent {
+	return &MailEvent{
+		MessageID: messageID,
+		Key:       timestamp.UTC().Format(time.RFC3339Nano) + "#" + eventType,
+		Type:      eventType,
+		Timestamp: timestamp,
+	}
+}
+
+// HasRecipient reports whether the event concerns the given address.
+func (e *MailEvent) HasRecipient(email string) bool {
+	for _, recipient := range e.Recipients {
+		if recipient == email {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats counts the messages with at least one event of each type.
+type Stats struct {
+	Messages int            `json:"messages"`
+	Types    map[string]int `json:"types"`
+}
+
+// DeliveryRate is the share of messages which were delivered.
+func (s Stats) DeliveryRate() float64 {
+	if s.Messages == 0 {
+		return 0
+	}
+	return float64(s.Types[TYPE_DELIVERY]) / float64(s.Messages)
+}
+
+func Summarize(events []*MailEvent) Stats {
+	messages := map[string]bool{}
+	seen := map[string]map[string]bool{}
+	for _, e := range events {
+		messages[e.MessageID] = true
+		if seen[e.Type] == nil {
+			seen[e.Type] = map[string]bool{}
+		}
+		seen[e.Type][e.MessageID] = true
+	}
+	stats := Stats{Messages: len(messages), Types: map[string]int{}}
+	for eventType, ids := range seen {
+		stats.Types[eventType] = len(ids)
+	}
+	return stats
+}
+
+// MailEventStore persists events about sent messages.
+type MailEventStore interface {
+	Put(e *MailEvent) error
+	GetForMessage(messageID string) (*[]*MailEvent, error)
+	GetForList(list string) (*[]*MailEvent, error)
+	GetForIssue(issue string) (*[]*MailEvent, error)
+}
@@ -0,0 +1,65 @@
+package mailevents
+
+import (
+	"sort"
+	"sync"
+)
+
+type memoryKey struct {
+	messageID string
+	key       string
+}
+
+// MemoryMailEventStore is a thread safe, in process MailEventStore.
+type MemoryMailEventStore struct {
+	mu     sync.RWMutex
+	events map[memoryKey]MailEvent
+}
+
+func NewMemoryMailEventStore() *MemoryMailEventStore {
+	return &MemoryMailEventStore{events: map[memoryKey]MailEvent{}}
+}
+
+func (s *MemoryMailEventStore) Put(e *MailEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := *e
+	stored.Recipients = append([]string{}, e.Recipients...)
+	s.events[memoryKey{e.MessageID, e.Key}] = stored
+	return nil
+}
+
+func (s *MemoryMailEventStore) GetForMessage(messageID string) (*[]*MailEvent, error) {
+	events := s.filter(func(e *MailEvent) bool { return e.MessageID == messageID })
+	return &events, nil
+}
+
+func (s *MemoryMailEventStore) GetForList(list string) (*[]*MailEvent, error) {
+	events := s.filter(func(e *MailEvent) bool { return e.List == list })
+	return &events, nil
+}
+
+func (s *MemoryMailEventStore) GetForIssue(issue string) (*[]*MailEvent, error) {
+	events := s.filter(func(e *MailEvent) bool { return e.Issue == issue })
+	return &events, nil
+}
+
+func (s *MemoryMailEventStore) filter(f func(*MailEvent) bool) []*MailEvent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	events := []*MailEvent{}
+	for _, e := range s.events {
+		e := e
+		e.Recipients = append([]string{}, e.Recipients...)
+		if f(&e) {
+			events = append(events, &e)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if events[i].MessageID != events[j].MessageID {
+			return events[i].MessageID < events[j].MessageID
+		}
+		return events[i].Key < events[j].Key
+	})
+	return events
+}
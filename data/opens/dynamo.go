@@ -0,0 +1,55 @@
+package opens
+
+import (
+	"time"
+
+	"github.com/guregu/dynamo"
+)
+
+// DynamoOpenStore is an OpenStore backed by a DynamoDB table keyed on issue
+// and subscriber.
+type DynamoOpenStore struct {
+	table dynamo.Table
+}
+
+func NewDynamoOpenStore(table dynamo.Table) *DynamoOpenStore {
+	return &DynamoOpenStore{table: table}
+}
+
+func (s *DynamoOpenStore) Record(issue, subscriber string, at time.Time, machine bool) error {
+	machineOpens := 0
+	if machine {
+		machineOpens = 1
+	}
+	return s.table.Update("issue", issue).Range("subscriber", subscriber).
+		Add("opens", 1).
+		Add("machine_opens", machineOpens).
+		SetIfNotExists("first_opened", at.Unix()).
+		Set("last_opened", at.Unix()).
+		Run()
+}
+
+func (s *DynamoOpenStore) RecordSent(issue, subscriber string, at time.Time) error {
+	return s.table.Update("issue", issue).Range("subscriber", subscriber).Set("sent", at.Unix()).Run()
+}
+
+func (s *DynamoOpenStore) Get(issue, subscriber string) (*SubscriberOpens, error) {
+	var o SubscriberOpens
+	err := s.table.Get("issue", issue).Range("subscriber", dynamo.Equal, subscriber).One(&o)
+	if err == dynamo.ErrNotFound {
+		return nil, ERR_OPENS_NOT_FOUND
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &o, nil
+}
+
+func (s *DynamoOpenStore) GetForIssue(issue string) (*[]*SubscriberOpens, error) {
+	var opens []*SubscriberOpens
+	err := s.table.Get("issue", issue).All(&opens)
+	if err != nil {
+		return nil, err
+	}
+	return &opens, nil
+}
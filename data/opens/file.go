@@ -0,0 +1,46 @@
+package opens
+
+import (
+	"sync"
+	"time"
+
+	"gjhr.me/newsletter/utils/jsonfile"
+)
+
+// FileOpenStore is a MemoryOpenStore which writes every change through to a
+// JSON file, for local runs that should survive restarts.
+type FileOpenStore struct {
+	*MemoryOpenStore
+	path   string
+	saveMu sync.Mutex
+}
+
+func NewFileOpenStore(path string) (*FileOpenStore, error) {
+	s := &FileOpenStore{MemoryOpenStore: NewMemoryOpenStore(), path: path}
+	var opens []*SubscriberOpens
+	err := jsonfile.Load(path, &opens)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range opens {
+		s.opens[memoryKey{o.Issue, o.Subscriber}] = *o
+	}
+	return s, nil
+}
+
+func (s *FileOpenStore) Record(issue, subscriber string, at time.Time, machine bool) error {
+	return s.save(s.MemoryOpenStore.Record(issue, subscriber, at, machine))
+}
+
+func (s *FileOpenStore) RecordSent(issue, subscriber string, at time.Time) error {
+	return s.save(s.MemoryOpenStore.RecordSent(issue, subscriber, at))
+}
+
+func (s *FileOpenStore) save(err error) error {
+	if err != nil {
+		return err
+	}
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	return jsonfile.Save(s.path, s.filter(func(*SubscriberOpens) bool { return true }))
+}
@@ -0,0 +1,86 @@
+package opens
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type memoryKey struct {
+	issue      string
+	subscriber string
+}
+
+// MemoryOpenStore is a thread safe, in process OpenStore.
+type MemoryOpenStore struct {
+	mu    sync.RWMutex
+	opens map[memoryKey]SubscriberOpens
+}
+
+func NewMemoryOpenStore() *MemoryOpenStore {
+	return &MemoryOpenStore{opens: map[memoryKey]SubscriberOpens{}}
+}
+
+func (s *MemoryOpenStore) Record(issue, subscriber string, at time.Time, machine bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := memoryKey{issue, subscriber}
+	stored, ok := s.opens[key]
+	if !ok {
+		stored = SubscriberOpens{Issue: issue, Subscriber: subscriber, FirstOpened: at}
+	}
+	stored.Opens++
+	if machine {
+		stored.MachineOpens++
+	}
+	stored.LastOpened = at
+	s.opens[key] = stored
+	return nil
+}
+
+func (s *MemoryOpenStore) RecordSent(issue, subscriber string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := memoryKey{issue, subscriber}
+	stored, ok := s.opens[key]
+	if !ok {
+		stored = SubscriberOpens{Issue: issue, Subscriber: subscriber}
+	}
+	stored.Sent = at
+	s.opens[key] = stored
+	return nil
+}
+
+func (s *MemoryOpenStore) Get(issue, subscriber string) (*SubscriberOpens, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	stored, ok := s.opens[memoryKey{issue, subscriber}]
+	if !ok {
+		return nil, ERR_OPENS_NOT_FOUND
+	}
+	return &stored, nil
+}
+
+func (s *MemoryOpenStore) GetForIssue(issue string) (*[]*SubscriberOpens, error) {
+	opens := s.filter(func(o *SubscriberOpens) bool { return o.Issue == issue })
+	return &opens, nil
+}
+
+func (s *MemoryOpenStore) filter(f func(*SubscriberOpens) bool) []*SubscriberOpens {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	opens := []*SubscriberOpens{}
+	for _, o := range s.opens {
+		o := o
+		if f(&o) {
+			opens = append(opens, &o)
+		}
+	}
+	sort.Slice(opens, func(i, j int) bool {
+		if opens[i].Issue != opens[j].Issue {
+			return opens[i].Issue < opens[j].Issue
+		}
+		return opens[i].Subscriber < opens[j].Subscriber
+	})
+	return opens
+}
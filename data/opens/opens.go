@@ -0,0 +1,66 @@
+package opens
+
+import (
+	"time"
+
+	"gjhr.me/newsletter/utils/consterror"
+)
+
+const (
+	ERR_OPENS_NOT_FOUND = consterror.ConstError("Opens not found")
+)
+
+// SubscriberOpens records the opens of an issue by one subscriber. Only a hash
+// of the subscriber is kept, no addresses or user agents.
+type SubscriberOpens struct {
+	Issue string `dynamo:"issue,hash" json:"issue"`
+	// Hash of the subscriber, see subscription.Subscription.Hash
+	Subscriber string `dynamo:"subscriber,range" json:"subscriber"`
+	Opens      int    `dynamo:"opens" json:"opens"`
+	// Opens which were likely made by a proxy or scanner rather than a reader
+	MachineOpens int       `dynamo:"machine_opens" json:"machine_opens"`
+	FirstOpened  time.Time `dynamo:"first_opened,unixtime" json:"first_opened"`
+	LastOpened   time.Time `dynamo:"last_opened,unixtime" json:"last_opened"`
+	// When the mail was accepted for delivery, zero if not recorded
+	Sent time.Time `dynamo:"sent,unixtime,omitempty" json:"sent,omitempty"`
+}
+
+// Human reports whether the subscriber likely read the issue themselves.
+func (o *SubscriberOpens) Human() bool {
+	return o.Opens > o.MachineOpens
+}
+
+// Stats summarises the opens of an issue.
+type Stats struct {
+	// Subscribers who opened the issue at all
+	Unique int `json:"unique"`
+	// Subscribers with at least one open which was not flagged as a machine
+	Human int `json:"human"`
+	Total int `json:"total"`
+}
+
+func Summarize(opens []*SubscriberOpens) Stats {
+	stats := Stats{}
+	for _, o := range opens {
+		// Sent issues are recorded before they are opened
+		if o.Opens == 0 {
+			continue
+		}
+		stats.Unique++
+		stats.Total += o.Opens
+		if o.Human() {
+			stats.Human++
+		}
+	}
+	return stats
+}
+
+// OpenStore records opens of issues.
+type OpenStore interface {
+	Record(issue, subscriber string, at time.Time, machine bool) error
+	// RecordSent records when the issue was accepted for delivery to the
+	// subscriber, so opens right after it can be told apart
+	RecordSent(issue, subscriber string, at time.Time) error
+	Get(issue, subscriber string) (*SubscriberOpens, error)
+	GetForIssue(issue string) (*[]*SubscriberOpens, error)
+}
@@ -0,0 +1,39 @@
+package pending
+
+import (
+	"sort"
+
+	"github.com/guregu/dynamo"
+)
+
+// DynamoPendingStore is a PendingStore backed by a DynamoDB table keyed on
+// list name and delivery prefixed item GUID.
+type DynamoPendingStore struct {
+	table dynamo.Table
+}
+
+func NewDynamoPendingStore(table dynamo.Table) *DynamoPendingStore {
+	return &DynamoPendingStore{table: table}
+}
+
+func (s *DynamoPendingStore) GetForList(list string, delivery string) ([]*PendingItem, error) {
+	var items []*PendingItem
+	err := s.table.Get("list", list).Range("key", dynamo.BeginsWith, delivery+"#").All(&items)
+	if err != nil {
+		return nil, err
+	}
+	sortByQueued(items)
+	return items, nil
+}
+
+func (s *DynamoPendingStore) Put(p *PendingItem) error {
+	return s.table.Put(p).Run()
+}
+
+func (s *DynamoPendingStore) Delete(list string, key string) error {
+	return s.table.Delete("list", list).Range("key", key).Run()
+}
+
+func sortByQueued(items []*PendingItem) {
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Queued.Before(items[j].Queued) })
+}
@@ -0,0 +1,45 @@
+package pending
+
+import (
+	"sync"
+
+	"gjhr.me/newsletter/utils/jsonfile"
+)
+
+// FilePendingStore is a MemoryPendingStore which writes every change through
+// to a JSON file, for local runs that should survive restarts.
+type FilePendingStore struct {
+	*MemoryPendingStore
+	path   string
+	saveMu sync.Mutex
+}
+
+func NewFilePendingStore(path string) (*FilePendingStore, error) {
+	s := &FilePendingStore{MemoryPendingStore: NewMemoryPendingStore(), path: path}
+	var items []*PendingItem
+	err := jsonfile.Load(path, &items)
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		s.MemoryPendingStore.Put(item)
+	}
+	return s, nil
+}
+
+func (s *FilePendingStore) Put(p *PendingItem) error {
+	return s.save(s.MemoryPendingStore.Put(p))
+}
+
+func (s *FilePendingStore) Delete(list string, key string) error {
+	return s.save(s.MemoryPendingStore.Delete(list, key))
+}
+
+func (s *FilePendingStore) save(err error) error {
+	if err != nil {
+		return err
+	}
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	return jsonfile.Save(s.path, s.all())
+}
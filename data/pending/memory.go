@@ -0,0 +1,65 @@
+package pending
+
+import (
+	"sync"
+)
+
+// MemoryPendingStore is a thread safe, in process PendingStore.
+type MemoryPendingStore struct {
+	mu    sync.RWMutex
+	items map[string]map[string]PendingItem
+}
+
+func NewMemoryPendingStore() *MemoryPendingStore {
+	return &MemoryPendingStore{items: map[string]map[string]PendingItem{}}
+}
+
+func (s *MemoryPendingStore) GetForList(list string, delivery string) ([]*PendingItem, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	items := []*PendingItem{}
+	for _, item := range s.items[list] {
+		if item.Delivery != delivery {
+			continue
+		}
+		item := item
+		items = append(items, &item)
+	}
+	sortByQueued(items)
+	return items, nil
+}
+
+func (s *MemoryPendingStore) Put(p *PendingItem) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.items[p.List] == nil {
+		s.items[p.List] = map[string]PendingItem{}
+	}
+	s.items[p.List][p.Key] = *p
+	return nil
+}
+
+func (s *MemoryPendingStore) Delete(list string, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.items[list], key)
+	if len(s.items[list]) == 0 {
+		delete(s.items, list)
+	}
+	return nil
+}
+
+// all returns every pending item, of every list.
+func (s *MemoryPendingStore) all() []*PendingItem {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	items := []*PendingItem{}
+	for _, list := range s.items {
+		for _, item := range list {
+			item := item
+			items = append(items, &item)
+		}
+	}
+	sortByQueued(items)
+	return items
+}
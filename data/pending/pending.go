@@ -0,0 +1,57 @@
+package pending
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// PendingItem is a feed item waiting to be sent in the next daily or weekly
+// digest of a list.
+type PendingItem struct {
+	List string `dynamo:"list,hash" json:"list"`
+	// Delivery and GUID, as an item is held once for each kind of digest
+	Key      string    `dynamo:"key,range" json:"key"`
+	Delivery string    `dynamo:"delivery" json:"delivery"`
+	GUID     string    `dynamo:"guid" json:"guid"`
+	FeedURL  string    `dynamo:"feed_url" json:"feed_url"`
+	Queued   time.Time `dynamo:"queued,unixtime" json:"queued"`
+	// The feed item as JSON, it is rendered when the digest is sent
+	Item string `dynamo:"item" json:"item"`
+}
+
+func New(list string, delivery string, feedURL string, item *gofeed.Item, queued time.Time) (*PendingItem, error) {
+	encoded, err := json.Marshal(item)
+	if err != nil {
+		return nil, err
+	}
+	return &PendingItem{
+		List:     list,
+		Key:      delivery + "#" + item.GUID,
+		Delivery: delivery,
+		GUID:     item.GUID,
+		FeedURL:  feedURL,
+		Queued:   queued,
+		Item:     string(encoded),
+	}, nil
+}
+
+// FeedItem decodes the feed item.
+func (p *PendingItem) FeedItem() (*gofeed.Item, error) {
+	var item gofeed.Item
+	err := json.Unmarshal([]byte(p.Item), &item)
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// PendingStore persists the items of lists waiting for their digest.
+type PendingStore interface {
+	// GetForList returns the items of a list pending for a kind of digest in
+	// the order they were queued.
+	GetForList(list string, delivery string) ([]*PendingItem, error)
+	Put(p *PendingItem) error
+	Delete(list string, key string) error
+}
@@ -0,0 +1,168 @@
+package subscription
+
+import (
+	"time"
+
+	"github.com/guregu/dynamo"
+)
+
+// DynamoSubscriptionStore is a SubscriptionStore backed by a DynamoDB table
+// keyed on list and email with "list-verified-all", "verification-token" and
+// "email" global secondary indexes.
+type DynamoSubscriptionStore struct {
+	table dynamo.Table
+}
+
+func NewDynamoSubscriptionStore(table dynamo.Table) *DynamoSubscriptionStore {
+	return &DynamoSubscriptionStore{table: table}
+}
+
+func (s *DynamoSubscriptionStore) Get(list, email string) (*Subscription, error) {
+	var sub Subscription
+	err := s.table.Get("list", list).Range("email", dynamo.Equal, email).One(&sub)
+	if err != nil {
+		return nil, ERR_SUBSCRIPTION_NOT_FOUND
+	}
+	return &sub, nil
+}
+
+func (s *DynamoSubscriptionStore) GetFromToken(token string) (*Subscription, error) {
+	var keys []*Subscription
+	err := s.table.Get("verification_token", token).Index("verification-token").All(&keys)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, ERR_SUBSCRIPTION_NOT_FOUND
+	}
+	if len(keys) > 1 {
+		return nil, ERR_MULTIPLE_SUBSCRIPTIONS_FOUND
+	}
+	// The index only holds keys
+	return s.Get(keys[0].List, keys[0].Email)
+}
+
+// GetAllFromList scans the table, as it is keyed on email first and only
+// verified subscriptions are indexed by list. It is only used by admin tools.
+func (s *DynamoSubscriptionStore) GetAllFromList(list string) (*[]*Subscription, error) {
+	var subs []*Subscription
+	err := s.table.Scan().Filter("'list' = ?", list).All(&subs)
+	if err != nil {
+		return nil, err
+	}
+	return &subs, nil
+}
+
+func (s *DynamoSubscriptionStore) GetAllVerifiedFromList(list string) (*[]*Subscription, error) {
+	var subs []*Subscription
+	// Unverified subscriptions have no verified attribute so are not in the index
+	err := s.table.Get("list", list).Index("list-verified-all").All(&subs)
+	if err != nil {
+		return nil, err
+	}
+	return &subs, nil
+}
+
+func (s *DynamoSubscriptionStore) GetAllForEmail(email string) (*[]*Subscription, error) {
+	var keys []*Subscription
+	err := s.table.Scan().Index("email").Filter("'email' = ?", email).All(&keys)
+	if err != nil {
+		return nil, err
+	}
+	// The index only holds keys
+	subs := []*Subscription{}
+	for _, key := range keys {
+		sub, err := s.Get(key.List, key.Email)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return &subs, nil
+}
+
+func (s *DynamoSubscriptionStore) DeleteAllForEmail(email string) error {
+	var subs []*Subscription
+	err := s.table.Scan().Index("email").Filter("'email' = ?", email).All(&subs)
+	if err != nil {
+		return err
+	}
+	// Attempt to delete all
+	hasErrored := false
+	for _, sub := range subs {
+		if s.Delete(sub) != nil {
+			// We must continue as this function is used to deal with bounces/complaints
+			hasErrored = true
+		}
+	}
+	if hasErrored {
+		return ERR_FAILED_TO_DELETE_ALL
+	}
+	return nil
+}
+
+func (s *DynamoSubscriptionStore) Put(sub *Subscription) error {
+	return s.table.Put(sub).Run()
+}
+
+func (s *DynamoSubscriptionStore) update(sub *Subscription) *dynamo.Update {
+	return s.table.Update("list", sub.List).Range("email", sub.Email)
+}
+
+func (s *DynamoSubscriptionStore) UpdateLastSentVerification(sub *Subscription) error {
+	return s.update(sub).Set("last_sent_verification", time.Now()).Run()
+}
+
+func (s *DynamoSubscriptionStore) UpdateLastSentUnsubscribe(sub *Subscription) error {
+	return s.update(sub).Set("last_sent_unsubscribe", time.Now().Unix()).Run()
+}
+
+func (s *DynamoSubscriptionStore) Verify(sub *Subscription) error {
+	return s.update(sub).Set("verified", "true").Run()
+}
+
+func (s *DynamoSubscriptionStore) UpdateSoftBounces(sub *Subscription) error {
+	if len(sub.SoftBounces) == 0 {
+		return s.update(sub).Remove("soft_bounces").Remove("soft_bounce_messages").Run()
+	}
+	return s.update(sub).Set("soft_bounces", sub.SoftBounces).Set("soft_bounce_messages", sub.SoftBounceMessages).Run()
+}
+
+func (s *DynamoSubscriptionStore) Suspend(sub *Subscription) error {
+	return s.update(sub).Set("suspended", true).Run()
+}
+
+func (s *DynamoSubscriptionStore) UpdatePreferences(sub *Subscription) error {
+	update := s.update(sub)
+	if sub.Delivery == "" {
+		update.Remove("delivery")
+	} else {
+		update.Set("delivery", sub.Delivery)
+	}
+	if sub.PausedUntil.IsZero() {
+		update.Remove("paused_until")
+	} else {
+		update.Set("paused_until", sub.PausedUntil.Unix())
+	}
+	if len(sub.Feeds) == 0 {
+		update.Remove("feeds")
+	} else {
+		update.SetSet("feeds", sub.Feeds)
+	}
+	return update.Run()
+}
+
+func (s *DynamoSubscriptionStore) UpdateFilter(sub *Subscription) error {
+	if sub.Filter.IsEmpty() {
+		return s.update(sub).Remove("filter").Run()
+	}
+	return s.update(sub).Set("filter", sub.Filter).Run()
+}
+
+func (s *DynamoSubscriptionStore) Reactivate(sub *Subscription) error {
+	return s.update(sub).Remove("suspended").Remove("soft_bounces").Remove("soft_bounce_messages").Run()
+}
+
+func (s *DynamoSubscriptionStore) Delete(sub *Subscription) error {
+	return s.table.Delete("list", sub.List).Range("email", sub.Email).Run()
+}
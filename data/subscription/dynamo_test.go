@@ -0,0 +1,434 @@
+package subscription
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/guregu/dynamo"
+	"gjhr.me/newsletter/itemfilter"
+	"gopkg.in/yaml.v3"
+)
+
+// keySchema names the hash and range key attributes of a table or index.
+type keySchema struct {
+	hash, rng string
+}
+
+type cfnKeySchema []struct {
+	AttributeName string `yaml:"AttributeName"`
+	KeyType       string `yaml:"KeyType"`
+}
+
+func (k cfnKeySchema) schema() keySchema {
+	schema := keySchema{}
+	for _, element := range k {
+		if element.KeyType == "HASH" {
+			schema.hash = element.AttributeName
+		} else {
+			schema.rng = element.AttributeName
+		}
+	}
+	return schema
+}
+
+type indexSchema struct {
+	key      keySchema
+	keysOnly bool
+}
+
+// loadTableSchema reads the key schema and indexes of the subscriptions table
+// from the CloudFormation template, so the fake matches what is deployed.
+func loadTableSchema(t *testing.T) (keySchema, map[string]indexSchema) {
+	t.Helper()
+	template, err := os.ReadFile("../../newsletter.cloudformation.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Resources struct {
+			SubscriptionsTable struct {
+				Properties struct {
+					KeySchema              cfnKeySchema `yaml:"KeySchema"`
+					GlobalSecondaryIndexes []struct {
+						IndexName  string       `yaml:"IndexName"`
+						KeySchema  cfnKeySchema `yaml:"KeySchema"`
+						Projection struct {
+							ProjectionType string `yaml:"ProjectionType"`
+						} `yaml:"Projection"`
+					} `yaml:"GlobalSecondaryIndexes"`
+				} `yaml:"Properties"`
+			} `yaml:"SubscriptionsTable"`
+		} `yaml:"Resources"`
+	}
+	err = yaml.Unmarshal(template, &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	props := doc.Resources.SubscriptionsTable.Properties
+	indexes := map[string]indexSchema{}
+	for _, index := range props.GlobalSecondaryIndexes {
+		indexes[index.IndexName] = indexSchema{key: index.KeySchema.schema(), keysOnly: index.Projection.ProjectionType == "KEYS_ONLY"}
+	}
+	return props.KeySchema.schema(), indexes
+}
+
+// item is a stored item, its attribute values kept as canonical JSON so they
+// can be compared.
+type item map[string]string
+
+// fakeDynamo serves the DynamoDB operations the store uses from memory. Unlike
+// the memory store it enforces the table's key schema, rejecting queries not
+// on a key, and projects indexes, returning only keys from KEYS_ONLY indexes.
+type fakeDynamo struct {
+	mu      sync.Mutex
+	key     keySchema
+	indexes map[string]indexSchema
+	items   []item
+}
+
+type dynamoRequest struct {
+	IndexName              string
+	Key                    map[string]json.RawMessage
+	Item                   map[string]json.RawMessage
+	KeyConditionExpression string
+	KeyConditions          map[string]struct {
+		AttributeValueList []json.RawMessage
+		ComparisonOperator string
+	}
+	FilterExpression          string
+	ExpressionAttributeNames  map[string]string
+	ExpressionAttributeValues map[string]json.RawMessage
+}
+
+type validationError string
+
+func newDynamoStore(t *testing.T) (*DynamoSubscriptionStore, *fakeDynamo) {
+	t.Helper()
+	key, indexes := loadTableSchema(t)
+	fake := &fakeDynamo{key: key, indexes: indexes}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	sess := session.Must(session.NewSession(&aws.Config{
+		Endpoint:    aws.String(server.URL),
+		Region:      aws.String("eu-west-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		MaxRetries:  aws.Int(0),
+	}))
+	return NewDynamoSubscriptionStore(dynamo.New(sess).Table("subscriptions")), fake
+}
+
+func (f *fakeDynamo) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	var req dynamoRequest
+	err := json.Unmarshal(body, &req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var res interface{}
+	switch op := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "DynamoDB_20120810."); op {
+	case "PutItem":
+		res, err = f.put(req)
+	case "GetItem":
+		res, err = f.get(req)
+	case "Query":
+		res, err = f.query(req, true)
+	case "Scan":
+		res, err = f.query(req, false)
+	default:
+		err = validationError("Unsupported operation " + op)
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"__type":  "com.amazonaws.dynamodb.v20120810#ValidationException",
+			"message": err.Error(),
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+func (e validationError) Error() string {
+	return string(e)
+}
+
+func canonical(raw json.RawMessage) string {
+	var v interface{}
+	json.Unmarshal(raw, &v)
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+func toItem(attrs map[string]json.RawMessage) item {
+	it := item{}
+	for name, value := range attrs {
+		it[name] = canonical(value)
+	}
+	return it
+}
+
+func (it item) raw() map[string]json.RawMessage {
+	attrs := map[string]json.RawMessage{}
+	for name, value := range it {
+		attrs[name] = json.RawMessage(value)
+	}
+	return attrs
+}
+
+func (f *fakeDynamo) sameKey(a, b item) bool {
+	return a[f.key.hash] == b[f.key.hash] && a[f.key.rng] == b[f.key.rng]
+}
+
+func (f *fakeDynamo) put(req dynamoRequest) (interface{}, error) {
+	it := toItem(req.Item)
+	if it[f.key.hash] == "" || it[f.key.rng] == "" {
+		return nil, validationError("Missing the key " + f.key.hash + " or " + f.key.rng + " in the item")
+	}
+	for i, existing := range f.items {
+		if f.sameKey(existing, it) {
+			f.items[i] = it
+			return map[string]interface{}{}, nil
+		}
+	}
+	f.items = append(f.items, it)
+	return map[string]interface{}{}, nil
+}
+
+func (f *fakeDynamo) get(req dynamoRequest) (interface{}, error) {
+	key := toItem(req.Key)
+	if len(key) != 2 || key[f.key.hash] == "" || key[f.key.rng] == "" {
+		return nil, validationError("The provided key element does not match the schema")
+	}
+	for _, it := range f.items {
+		if f.sameKey(it, key) {
+			return map[string]interface{}{"Item": it.raw()}, nil
+		}
+	}
+	return map[string]interface{}{}, nil
+}
+
+// query runs a Query, or a Scan, against the table or an index.
+func (f *fakeDynamo) query(req dynamoRequest, isQuery bool) (interface{}, error) {
+	key := f.key
+	keysOnly := false
+	if req.IndexName != "" {
+		index, ok := f.indexes[req.IndexName]
+		if !ok {
+			return nil, validationError("The table does not have the specified index: " + req.IndexName)
+		}
+		key, keysOnly = index.key, index.keysOnly
+	}
+	var keyConditions map[string]string
+	if isQuery {
+		var err error
+		keyConditions, err = parseConditions(req.KeyConditionExpression, req)
+		if err != nil {
+			return nil, err
+		}
+		for name, condition := range req.KeyConditions {
+			if condition.ComparisonOperator != "EQ" || len(condition.AttributeValueList) != 1 {
+				return nil, validationError("Unsupported key condition on " + name)
+			}
+			keyConditions[name] = canonical(condition.AttributeValueList[0])
+		}
+		if _, ok := keyConditions[key.hash]; !ok {
+			return nil, validationError("Query condition missed key schema element: " + key.hash)
+		}
+		for name := range keyConditions {
+			if name != key.hash && name != key.rng {
+				return nil, validationError("Query key condition not supported on " + name)
+			}
+		}
+	}
+	filter, err := parseConditions(req.FilterExpression, req)
+	if err != nil {
+		return nil, err
+	}
+
+	items := []map[string]json.RawMessage{}
+	for _, it := range f.items {
+		// Indexes only hold items with their key attributes
+		if it[key.hash] == "" || (key.rng != "" && it[key.rng] == "") {
+			continue
+		}
+		if !it.matches(keyConditions) || !it.matches(filter) {
+			continue
+		}
+		if keysOnly {
+			projected := item{}
+			for _, name := range []string{f.key.hash, f.key.rng, key.hash, key.rng} {
+				if name != "" {
+					projected[name] = it[name]
+				}
+			}
+			it = projected
+		}
+		items = append(items, it.raw())
+	}
+	return map[string]interface{}{"Items": items, "Count": len(items), "ScannedCount": len(items)}, nil
+}
+
+func (it item) matches(conditions map[string]string) bool {
+	for name, value := range conditions {
+		if it[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+var conditionPattern = regexp.MustCompile(`^\(?\s*(\S+)\s*=\s*(\S+?)\s*\)?$`)
+
+// parseConditions reads an expression of equality conditions joined by AND,
+// the only expressions the store uses, into the values by attribute name.
+func parseConditions(expr string, req dynamoRequest) (map[string]string, error) {
+	conditions := map[string]string{}
+	if strings.TrimSpace(expr) == "" {
+		return conditions, nil
+	}
+	for _, part := range regexp.MustCompile(`(?i)\s+AND\s+`).Split(expr, -1) {
+		match := conditionPattern.FindStringSubmatch(strings.TrimSpace(part))
+		if match == nil {
+			return nil, validationError(fmt.Sprintf("Unsupported condition %q", part))
+		}
+		name, placeholder := match[1], match[2]
+		if resolved, ok := req.ExpressionAttributeNames[name]; ok {
+			name = resolved
+		}
+		value, ok := req.ExpressionAttributeValues[placeholder]
+		if !ok {
+			return nil, validationError(fmt.Sprintf("Unsupported condition %q", part))
+		}
+		conditions[name] = canonical(value)
+	}
+	return conditions, nil
+}
+
+func putAll(t *testing.T, store SubscriptionStore, subs ...*Subscription) {
+	t.Helper()
+	for _, sub := range subs {
+		err := store.Put(sub)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func emails(subs *[]*Subscription) []string {
+	result := []string{}
+	for _, sub := range *subs {
+		result = append(result, sub.List+"/"+sub.Email)
+	}
+	return result
+}
+
+func TestDynamoGetAllFromList(t *testing.T) {
+	store, _ := newDynamoStore(t)
+	putAll(t, store,
+		&Subscription{Email: "verified@example.com", List: "a", VerificationToken: "t1", Verified: "true"},
+		&Subscription{Email: "pending@example.com", List: "a", VerificationToken: "t2"},
+		&Subscription{Email: "verified@example.com", List: "b", VerificationToken: "t3", Verified: "true"},
+	)
+
+	subs, err := store.GetAllFromList("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := emails(subs); len(got) != 2 || !containsAll(got, "a/verified@example.com", "a/pending@example.com") {
+		t.Errorf("got %v, want both subscriptions to a", got)
+	}
+
+	subs, err = store.GetAllVerifiedFromList("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := emails(subs); len(got) != 1 || got[0] != "a/verified@example.com" {
+		t.Errorf("got %v, want only the verified subscription to a", got)
+	}
+}
+
+func containsAll(values []string, wanted ...string) bool {
+	for _, w := range wanted {
+		found := false
+		for _, v := range values {
+			found = found || v == w
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDynamoGetFromTokenReturnsWholeSubscription(t *testing.T) {
+	store, _ := newDynamoStore(t)
+	subscribed := time.Unix(1700000000, 0)
+	stored := &Subscription{
+		Email:             "new@example.com",
+		List:              "a",
+		VerificationToken: "token",
+		Verified:          "true",
+		Suspended:         true,
+		Delivery:          "weekly",
+		Subscribed:        subscribed,
+		PausedUntil:       subscribed.AddDate(0, 1, 0),
+		Feeds:             []string{"go"},
+		ReplacesEmail:     "old@example.com",
+		Filter:            &itemfilter.Filter{Exclude: []itemfilter.Rule{{Categories: []string{"beta"}}}},
+	}
+	putAll(t, store, stored, &Subscription{Email: "other@example.com", List: "a", VerificationToken: "other"})
+
+	sub, err := store.GetFromToken("token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Email != stored.Email || sub.List != stored.List || sub.Verified != stored.Verified ||
+		!sub.Suspended || sub.Delivery != stored.Delivery || !sub.Subscribed.Equal(stored.Subscribed) ||
+		!sub.PausedUntil.Equal(stored.PausedUntil) || len(sub.Feeds) != 1 || sub.Feeds[0] != "go" ||
+		sub.ReplacesEmail != stored.ReplacesEmail || sub.Filter == nil || len(sub.Filter.Exclude) != 1 {
+		t.Errorf("got %+v, want %+v", sub, stored)
+	}
+
+	_, err = store.GetFromToken("unknown")
+	if err != ERR_SUBSCRIPTION_NOT_FOUND {
+		t.Errorf("got error %v, want %v", err, ERR_SUBSCRIPTION_NOT_FOUND)
+	}
+}
+
+func TestDynamoGetAllForEmailReturnsWholeSubscriptions(t *testing.T) {
+	store, _ := newDynamoStore(t)
+	putAll(t, store,
+		&Subscription{Email: "reader@example.com", List: "a", VerificationToken: "t1", Delivery: "daily"},
+		&Subscription{Email: "reader@example.com", List: "b", VerificationToken: "t2", Delivery: "weekly"},
+		&Subscription{Email: "other@example.com", List: "a", VerificationToken: "t3"},
+	)
+
+	subs, err := store.GetAllForEmail("reader@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*subs) != 2 {
+		t.Fatalf("got %v, want both subscriptions of reader@example.com", emails(subs))
+	}
+	for _, sub := range *subs {
+		if sub.Delivery == "" || sub.VerificationToken == "" {
+			t.Errorf("got key only subscription %+v", sub)
+		}
+	}
+}
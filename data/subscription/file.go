@@ -0,0 +1,81 @@
+package subscription
+
+import (
+	"sync"
+
+	"gjhr.me/newsletter/utils/jsonfile"
+)
+
+// FileSubscriptionStore is a MemorySubscriptionStore which writes every change
+// through to a JSON file, for local runs that should survive restarts.
+type FileSubscriptionStore struct {
+	*MemorySubscriptionStore
+	path   string
+	saveMu sync.Mutex
+}
+
+func NewFileSubscriptionStore(path string) (*FileSubscriptionStore, error) {
+	s := &FileSubscriptionStore{MemorySubscriptionStore: NewMemorySubscriptionStore(), path: path}
+	var subs []*Subscription
+	err := jsonfile.Load(path, &subs)
+	if err != nil {
+		return nil, err
+	}
+	for _, sub := range subs {
+		s.MemorySubscriptionStore.Put(sub)
+	}
+	return s, nil
+}
+
+func (s *FileSubscriptionStore) DeleteAllForEmail(email string) error {
+	return s.save(s.MemorySubscriptionStore.DeleteAllForEmail(email))
+}
+
+func (s *FileSubscriptionStore) Put(sub *Subscription) error {
+	return s.save(s.MemorySubscriptionStore.Put(sub))
+}
+
+func (s *FileSubscriptionStore) UpdateLastSentVerification(sub *Subscription) error {
+	return s.save(s.MemorySubscriptionStore.UpdateLastSentVerification(sub))
+}
+
+func (s *FileSubscriptionStore) UpdateLastSentUnsubscribe(sub *Subscription) error {
+	return s.save(s.MemorySubscriptionStore.UpdateLastSentUnsubscribe(sub))
+}
+
+func (s *FileSubscriptionStore) Verify(sub *Subscription) error {
+	return s.save(s.MemorySubscriptionStore.Verify(sub))
+}
+
+func (s *FileSubscriptionStore) UpdateSoftBounces(sub *Subscription) error {
+	return s.save(s.MemorySubscriptionStore.UpdateSoftBounces(sub))
+}
+
+func (s *FileSubscriptionStore) Suspend(sub *Subscription) error {
+	return s.save(s.MemorySubscriptionStore.Suspend(sub))
+}
+
+func (s *FileSubscriptionStore) UpdatePreferences(sub *Subscription) error {
+	return s.save(s.MemorySubscriptionStore.UpdatePreferences(sub))
+}
+
+func (s *FileSubscriptionStore) UpdateFilter(sub *Subscription) error {
+	return s.save(s.MemorySubscriptionStore.UpdateFilter(sub))
+}
+
+func (s *FileSubscriptionStore) Reactivate(sub *Subscription) error {
+	return s.save(s.MemorySubscriptionStore.Reactivate(sub))
+}
+
+func (s *FileSubscriptionStore) Delete(sub *Subscription) error {
+	return s.save(s.MemorySubscriptionStore.Delete(sub))
+}
+
+func (s *FileSubscriptionStore) save(err error) error {
+	if err != nil {
+		return err
+	}
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	return jsonfile.Save(s.path, s.filter(func(*Subscription) bool { return true }))
+}
@@ -0,0 +1,169 @@
+package subscription
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type memoryKey struct {
+	list  string
+	email string
+}
+
+// MemorySubscriptionStore is a thread safe, in process SubscriptionStore.
+// Subscriptions are copied on the way in and out so callers never share state
+// with the store.
+type MemorySubscriptionStore struct {
+	mu   sync.RWMutex
+	subs map[memoryKey]Subscription
+}
+
+func NewMemorySubscriptionStore() *MemorySubscriptionStore {
+	return &MemorySubscriptionStore{subs: map[memoryKey]Subscription{}}
+}
+
+func (s *MemorySubscriptionStore) Get(list, email string) (*Subscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sub, ok := s.subs[memoryKey{list, email}]
+	if !ok {
+		return nil, ERR_SUBSCRIPTION_NOT_FOUND
+	}
+	sub = sub.clone()
+	return &sub, nil
+}
+
+func (s *MemorySubscriptionStore) GetFromToken(token string) (*Subscription, error) {
+	subs := s.filter(func(sub *Subscription) bool { return sub.VerificationToken == token })
+	if len(subs) == 0 {
+		return nil, ERR_SUBSCRIPTION_NOT_FOUND
+	}
+	if len(subs) > 1 {
+		return nil, ERR_MULTIPLE_SUBSCRIPTIONS_FOUND
+	}
+	return subs[0], nil
+}
+
+func (s *MemorySubscriptionStore) GetAllFromList(list string) (*[]*Subscription, error) {
+	subs := s.filter(func(sub *Subscription) bool { return sub.List == list })
+	return &subs, nil
+}
+
+func (s *MemorySubscriptionStore) GetAllVerifiedFromList(list string) (*[]*Subscription, error) {
+	subs := s.filter(func(sub *Subscription) bool { return sub.List == list && sub.Verified != "" })
+	return &subs, nil
+}
+
+func (s *MemorySubscriptionStore) GetAllForEmail(email string) (*[]*Subscription, error) {
+	subs := s.filter(func(sub *Subscription) bool { return sub.Email == email })
+	return &subs, nil
+}
+
+func (s *MemorySubscriptionStore) DeleteAllForEmail(email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.subs {
+		if key.email == email {
+			delete(s.subs, key)
+		}
+	}
+	return nil
+}
+
+func (s *MemorySubscriptionStore) Put(sub *Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subs[memoryKey{sub.List, sub.Email}] = sub.clone()
+	return nil
+}
+
+func (s *MemorySubscriptionStore) UpdateLastSentVerification(sub *Subscription) error {
+	return s.update(sub, func(stored *Subscription) { stored.LastSentVerification = time.Now() })
+}
+
+func (s *MemorySubscriptionStore) UpdateLastSentUnsubscribe(sub *Subscription) error {
+	return s.update(sub, func(stored *Subscription) { stored.LastSentUnsubscribe = time.Now() })
+}
+
+func (s *MemorySubscriptionStore) Verify(sub *Subscription) error {
+	return s.update(sub, func(stored *Subscription) { stored.Verified = "true" })
+}
+
+func (s *MemorySubscriptionStore) UpdateSoftBounces(sub *Subscription) error {
+	return s.update(sub, func(stored *Subscription) {
+		stored.SoftBounces = append([]time.Time(nil), sub.SoftBounces...)
+		stored.SoftBounceMessages = append([]string(nil), sub.SoftBounceMessages...)
+	})
+}
+
+func (s *MemorySubscriptionStore) Suspend(sub *Subscription) error {
+	return s.update(sub, func(stored *Subscription) { stored.Suspended = true })
+}
+
+func (s *MemorySubscriptionStore) UpdatePreferences(sub *Subscription) error {
+	return s.update(sub, func(stored *Subscription) {
+		stored.Delivery = sub.Delivery
+		stored.PausedUntil = sub.PausedUntil
+		stored.Feeds = append([]string(nil), sub.Feeds...)
+	})
+}
+
+func (s *MemorySubscriptionStore) UpdateFilter(sub *Subscription) error {
+	return s.update(sub, func(stored *Subscription) { stored.Filter = sub.Filter.Copy() })
+}
+
+func (s *MemorySubscriptionStore) Reactivate(sub *Subscription) error {
+	return s.update(sub, func(stored *Subscription) {
+		stored.Suspended = false
+		stored.SoftBounces = nil
+		stored.SoftBounceMessages = nil
+	})
+}
+
+func (s *MemorySubscriptionStore) Delete(sub *Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.subs, memoryKey{sub.List, sub.Email})
+	return nil
+}
+
+func (s *MemorySubscriptionStore) update(sub *Subscription, f func(*Subscription)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := memoryKey{sub.List, sub.Email}
+	stored, ok := s.subs[key]
+	if !ok {
+		return ERR_SUBSCRIPTION_NOT_FOUND
+	}
+	f(&stored)
+	s.subs[key] = stored
+	return nil
+}
+
+func (s *MemorySubscriptionStore) filter(f func(*Subscription) bool) []*Subscription {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	subs := []*Subscription{}
+	for _, sub := range s.subs {
+		sub := sub.clone()
+		if f(&sub) {
+			subs = append(subs, &sub)
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		if subs[i].List != subs[j].List {
+			return subs[i].List < subs[j].List
+		}
+		return subs[i].Email < subs[j].Email
+	})
+	return subs
+}
+
+func (sub Subscription) clone() Subscription {
+	sub.SoftBounces = append([]time.Time(nil), sub.SoftBounces...)
+	sub.SoftBounceMessages = append([]string(nil), sub.SoftBounceMessages...)
+	sub.Feeds = append([]string(nil), sub.Feeds...)
+	sub.Filter = sub.Filter.Copy()
+	return sub
+}
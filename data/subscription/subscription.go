@@ -1,11 +1,11 @@
 package subscription
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"time"
 
-	"github.com/guregu/dynamo"
-	"gjhr.me/newsletter/providers/aws"
-	"gjhr.me/newsletter/providers/config"
+	"gjhr.me/newsletter/itemfilter"
 	"gjhr.me/newsletter/utils/consterror"
 )
 
@@ -15,90 +15,118 @@ const (
 	ERR_FAILED_TO_DELETE_ALL         = consterror.ConstError("One or more subscriptions failed to be deleted")
 )
 
-var table dynamo.Table
-
-func init() {
-	table = aws.Dynamo().Table(config.Get().SubscriptionsTable)
-}
-
 type Subscription struct {
 	Email                string    `dynamo:"email" json:"email"`
 	List                 string    `dynamo:"list" json:"list"`
 	VerificationToken    string    `dynamo:"verification_token" json:"verification_token"`
 	Verified             string    `dynamo:"verified,omitempty" json:"verified"`
-	LastSentVerification time.Time `dynamo:"last_sent_verification,unixtime" json:"-"`
+	LastSentVerification time.Time `dynamo:"last_sent_verification,unixtime" json:"last_sent_verification"`
+	// When an unsubscribe link was last emailed on request
+	LastSentUnsubscribe time.Time `dynamo:"last_sent_unsubscribe,unixtime,omitempty" json:"last_sent_unsubscribe,omitempty"`
+	// Times of recent transient bounces, see AddSoftBounce
+	SoftBounces []time.Time `dynamo:"soft_bounces,omitempty" json:"soft_bounces,omitempty"`
+	// SES message IDs of the mail which bounced at each of SoftBounces, so
+	// redelivered notifications are not counted twice
+	SoftBounceMessages []string `dynamo:"soft_bounce_messages,omitempty" json:"soft_bounce_messages,omitempty"`
+	// Suspended subscriptions are kept but not sent issues
+	Suspended bool `dynamo:"suspended,omitempty" json:"suspended,omitempty"`
+	// Delivery chosen by the subscriber, empty for the list's
+	Delivery string `dynamo:"delivery,omitempty" json:"delivery,omitempty"`
+	// When the address subscribed, zero for subscriptions from before this
+	// was recorded
+	Subscribed time.Time `dynamo:"subscribed,unixtime,omitempty" json:"subscribed,omitempty"`
+	// Nothing is sent before this time
+	PausedUntil time.Time `dynamo:"paused_until,unixtime,omitempty" json:"paused_until,omitempty"`
+	// Keys of the list's feeds the subscriber wants, empty for all of them
+	Feeds []string `dynamo:"feeds,set,omitempty" json:"feeds,omitempty"`
+	// Address whose subscription this replaces once verified, after a change
+	// of address
+	ReplacesEmail string `dynamo:"replaces_email,omitempty" json:"replaces_email,omitempty"`
+	// Which of the items sent by the list the subscriber wants, nil for all
+	// of them
+	Filter *itemfilter.Filter `dynamo:"filter,omitempty" json:"filter,omitempty"`
 }
 
-func Get(list, email string) (*Subscription, error) {
-	var sub Subscription
-	err := table.Get("list", list).Range("email", dynamo.Equal, email).One(&sub)
-	if err != nil {
-		return nil, ERR_SUBSCRIPTION_NOT_FOUND
-	}
-	return &sub, nil
+// SubscriptionStore persists subscriptions of email addresses to lists.
+type SubscriptionStore interface {
+	Get(list, email string) (*Subscription, error)
+	GetFromToken(token string) (*Subscription, error)
+	GetAllFromList(list string) (*[]*Subscription, error)
+	GetAllVerifiedFromList(list string) (*[]*Subscription, error)
+	GetAllForEmail(email string) (*[]*Subscription, error)
+	DeleteAllForEmail(email string) error
+	Put(sub *Subscription) error
+	UpdateLastSentVerification(sub *Subscription) error
+	UpdateLastSentUnsubscribe(sub *Subscription) error
+	Verify(sub *Subscription) error
+	UpdateSoftBounces(sub *Subscription) error
+	Suspend(sub *Subscription) error
+	// UpdatePreferences saves the delivery, pause and feeds of a subscription
+	UpdatePreferences(sub *Subscription) error
+	UpdateFilter(sub *Subscription) error
+	// Reactivate lifts a suspension and forgets past soft bounces
+	Reactivate(sub *Subscription) error
+	Delete(sub *Subscription) error
 }
 
-func GetFromToken(token string) (*Subscription, error) {
-	var subs []*Subscription
-	err := table.Scan().Index("verification-token").Filter("'verification_token' = ?", token).All(&subs)
-	if err != nil {
-		return nil, err
-	}
-	if len(subs) == 0 {
-		return nil, ERR_SUBSCRIPTION_NOT_FOUND
-	}
-	if len(subs) > 1 {
-		return nil, ERR_MULTIPLE_SUBSCRIPTIONS_FOUND
-	}
-	return subs[0], nil
+// Hash identifies the subscriber in statistics without storing their address.
+func (s Subscription) Hash() string {
+	sum := sha256.Sum256([]byte(s.List + "\x00" + s.Email))
+	return hex.EncodeToString(sum[:16])
 }
 
-func GetAllVerifiedFromList(list string) (*[]*Subscription, error) {
-	var subs []*Subscription
-	err := table.Scan().Index("list-verified").Filter("'list' = ?", list).All(&subs)
-	if err != nil {
-		return nil, err
-	}
-	return &subs, nil
-
+// LinkID identifies the subscription in links sent to the subscriber. It is
+// derived from the verification token, so it changes when the address
+// resubscribes, but cannot be used to verify it.
+func (s Subscription) LinkID() string {
+	sum := sha256.Sum256([]byte("link\x00" + s.VerificationToken))
+	return hex.EncodeToString(sum[:16])
 }
 
-func DeleteAllForEmail(email string) error {
-	var subs []*Subscription
-	err := table.Scan().Index("email").Filter("'email' = ?", email).All(&subs)
-	if err != nil {
-		return err
-	}
-	// Attempt to delete all
-	hasErrored := false
-	for _, sub := range subs {
-		if sub.Delete() != nil {
-			// We must continue as this function is used to deal with bounces/complaints
-			hasErrored = true
+// AddSoftBounce records a transient bounce of a mail at the given time,
+// forgetting those older than the window, and returns the number of bounces
+// within it. A bounce of a mail already recorded is not counted again.
+func (s *Subscription) AddSoftBounce(messageID string, at time.Time, window time.Duration) int {
+	recent := []time.Time{}
+	messages := []string{}
+	counted := false
+	for i, bounce := range s.SoftBounces {
+		if !bounce.After(at.Add(-window)) {
+			continue
 		}
+		// Bounces recorded before their messages were have none
+		message := ""
+		if i < len(s.SoftBounceMessages) {
+			message = s.SoftBounceMessages[i]
+		}
+		counted = counted || messageID != "" && message == messageID
+		recent = append(recent, bounce)
+		messages = append(messages, message)
 	}
-	if hasErrored {
-		return ERR_FAILED_TO_DELETE_ALL
+	if !counted {
+		recent = append(recent, at)
+		messages = append(messages, messageID)
 	}
-	return nil
-}
-
-func Put(sub *Subscription) error {
-	return table.Put(sub).Run()
-}
-
-func (sub *Subscription) update() *dynamo.Update {
-	return table.Update("list", sub.List).Range("email", sub.Email)
+	s.SoftBounces = recent
+	s.SoftBounceMessages = messages
+	return len(s.SoftBounces)
 }
 
-func (sub *Subscription) UpdateLastSentVerification() error {
-	return sub.update().Set("last_sent_verification", time.Now()).Run()
+// IsPaused reports whether delivery is paused at the given time.
+func (s *Subscription) IsPaused(at time.Time) bool {
+	return at.Before(s.PausedUntil)
 }
 
-func (sub *Subscription) Verify() error {
-	return sub.update().Set("verified", "true").Run()
-}
-
-func (sub *Subscription) Delete() error {
-	return table.Delete("list", sub.List).Range("email", sub.Email).Run()
+// WantsFeed reports whether the subscriber wants items of the feed with the
+// given key.
+func (s *Subscription) WantsFeed(key string) bool {
+	if len(s.Feeds) == 0 {
+		return true
+	}
+	for _, feed := range s.Feeds {
+		if feed == key {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,44 @@
+package suppression
+
+import (
+	"github.com/guregu/dynamo"
+)
+
+// DynamoSuppressionStore is a SuppressionStore backed by a DynamoDB table keyed
+// on email hash.
+type DynamoSuppressionStore struct {
+	table dynamo.Table
+}
+
+func NewDynamoSuppressionStore(table dynamo.Table) *DynamoSuppressionStore {
+	return &DynamoSuppressionStore{table: table}
+}
+
+func (s *DynamoSuppressionStore) Get(emailHash string) (*Suppression, error) {
+	var suppression Suppression
+	err := s.table.Get("email_hash", emailHash).One(&suppression)
+	if err == dynamo.ErrNotFound {
+		return nil, ERR_NOT_SUPPRESSED
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &suppression, nil
+}
+
+func (s *DynamoSuppressionStore) GetAll() (*[]*Suppression, error) {
+	var suppressions []*Suppression
+	err := s.table.Scan().All(&suppressions)
+	if err != nil {
+		return nil, err
+	}
+	return &suppressions, nil
+}
+
+func (s *DynamoSuppressionStore) Put(suppression *Suppression) error {
+	return s.table.Put(suppression).Run()
+}
+
+func (s *DynamoSuppressionStore) Delete(emailHash string) error {
+	return s.table.Delete("email_hash", emailHash).Run()
+}
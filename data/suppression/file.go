@@ -0,0 +1,49 @@
+package suppression
+
+import (
+	"sync"
+
+	"gjhr.me/newsletter/utils/jsonfile"
+)
+
+// FileSuppressionStore is a MemorySuppressionStore which writes every change
+// through to a JSON file, for local runs that should survive restarts.
+type FileSuppressionStore struct {
+	*MemorySuppressionStore
+	path   string
+	saveMu sync.Mutex
+}
+
+func NewFileSuppressionStore(path string) (*FileSuppressionStore, error) {
+	s := &FileSuppressionStore{MemorySuppressionStore: NewMemorySuppressionStore(), path: path}
+	var suppressions []*Suppression
+	err := jsonfile.Load(path, &suppressions)
+	if err != nil {
+		return nil, err
+	}
+	for _, suppression := range suppressions {
+		s.MemorySuppressionStore.Put(suppression)
+	}
+	return s, nil
+}
+
+func (s *FileSuppressionStore) Put(suppression *Suppression) error {
+	return s.save(s.MemorySuppressionStore.Put(suppression))
+}
+
+func (s *FileSuppressionStore) Delete(emailHash string) error {
+	return s.save(s.MemorySuppressionStore.Delete(emailHash))
+}
+
+func (s *FileSuppressionStore) save(err error) error {
+	if err != nil {
+		return err
+	}
+	s.saveMu.Lock()
+	defer s.saveMu.Unlock()
+	suppressions, err := s.GetAll()
+	if err != nil {
+		return err
+	}
+	return jsonfile.Save(s.path, suppressions)
+}
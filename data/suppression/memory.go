@@ -0,0 +1,52 @@
+package suppression
+
+import (
+	"sort"
+	"sync"
+)
+
+// MemorySuppressionStore is a thread safe, in process SuppressionStore.
+type MemorySuppressionStore struct {
+	mu           sync.RWMutex
+	suppressions map[string]Suppression
+}
+
+func NewMemorySuppressionStore() *MemorySuppressionStore {
+	return &MemorySuppressionStore{suppressions: map[string]Suppression{}}
+}
+
+func (s *MemorySuppressionStore) Get(emailHash string) (*Suppression, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	suppression, ok := s.suppressions[emailHash]
+	if !ok {
+		return nil, ERR_NOT_SUPPRESSED
+	}
+	return &suppression, nil
+}
+
+func (s *MemorySuppressionStore) GetAll() (*[]*Suppression, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	suppressions := []*Suppression{}
+	for _, suppression := range s.suppressions {
+		suppression := suppression
+		suppressions = append(suppressions, &suppression)
+	}
+	sort.Slice(suppressions, func(i, j int) bool { return suppressions[i].Timestamp.Before(suppressions[j].Timestamp) })
+	return &suppressions, nil
+}
+
+func (s *MemorySuppressionStore) Put(suppression *Suppression) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.suppressions[suppression.EmailHash] = *suppression
+	return nil
+}
+
+func (s *MemorySuppressionStore) Delete(emailHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.suppressions, emailHash)
+	return nil
+}
@@ -0,0 +1,90 @@
+package suppression
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"gjhr.me/newsletter/utils/consterror"
+)
+
+const (
+	ERR_NOT_SUPPRESSED = consterror.ConstError("Address is not suppressed")
+)
+
+// Reasons an address is suppressed.
+const (
+	REASON_BOUNCE    = "bounce"
+	REASON_COMPLAINT = "complaint"
+	REASON_MANUAL    = "manual"
+)
+
+// Suppression stops all mail to an address, across lists. Only a hash of the
+// address is kept.
+type Suppression struct {
+	EmailHash string    `dynamo:"email_hash,hash" json:"email_hash"`
+	Reason    string    `dynamo:"reason" json:"reason"`
+	MessageID string    `dynamo:"message_id,omitempty" json:"message_id,omitempty"`
+	Timestamp time.Time `dynamo:"timestamp,unixtime" json:"timestamp"`
+	// What the receiving server or complaint said, for audit
+	Diagnostic string `dynamo:"diagnostic,omitempty" json:"diagnostic,omitempty"`
+}
+
+func New(email string, reason string, messageID string, timestamp time.Time) *Suppression {
+	return &Suppression{
+		EmailHash: Hash(email),
+		Reason:    reason,
+		MessageID: messageID,
+		Timestamp: timestamp,
+	}
+}
+
+// Hash identifies an address in the suppression list. Addresses are compared
+// case insensitively.
+func Hash(email string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(email))))
+	return hex.EncodeToString(sum[:])
+}
+
+// SuppressionStore persists suppressed addresses.
+type SuppressionStore interface {
+	Get(emailHash string) (*Suppression, error)
+	GetAll() (*[]*Suppression, error)
+	Put(s *Suppression) error
+	Delete(emailHash string) error
+}
+
+// IsSuppressed reports whether mail to the address is suppressed.
+func IsSuppressed(store SuppressionStore, email string) (bool, error) {
+	_, err := store.Get(Hash(email))
+	if err == ERR_NOT_SUPPRESSED {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Set holds the hashes of suppressed addresses, to check many addresses
+// without a lookup each.
+type Set map[string]bool
+
+// LoadSet gets every suppressed address from the store.
+func LoadSet(store SuppressionStore) (Set, error) {
+	suppressions, err := store.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	set := Set{}
+	for _, s := range *suppressions {
+		set[s.EmailHash] = true
+	}
+	return set, nil
+}
+
+// Contains reports whether mail to the address is suppressed.
+func (s Set) Contains(email string) bool {
+	return s[Hash(email)]
+}
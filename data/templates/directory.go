@@ -0,0 +1,42 @@
+package templates
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// DirectoryTemplateStore is a TemplateStore keeping one file per template in a
+// local directory.
+type DirectoryTemplateStore struct {
+	directory string
+}
+
+func NewDirectoryTemplateStore(directory string) *DirectoryTemplateStore {
+	return &DirectoryTemplateStore{directory: directory}
+}
+
+func (s *DirectoryTemplateStore) Get(key string) (string, error) {
+	body, err := os.ReadFile(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", ERR_TEMPLATE_NOT_FOUND
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+func (s *DirectoryTemplateStore) Put(key, body string) error {
+	path := s.path(key)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(body), 0644)
+}
+
+func (s *DirectoryTemplateStore) path(key string) string {
+	return filepath.Join(s.directory, filepath.FromSlash(filepath.Clean("/"+key)))
+}
@@ -0,0 +1,30 @@
+package templates
+
+import "sync"
+
+// MemoryTemplateStore is a thread safe, in process TemplateStore.
+type MemoryTemplateStore struct {
+	mu        sync.RWMutex
+	templates map[string]string
+}
+
+func NewMemoryTemplateStore() *MemoryTemplateStore {
+	return &MemoryTemplateStore{templates: map[string]string{}}
+}
+
+func (s *MemoryTemplateStore) Get(key string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	body, ok := s.templates[key]
+	if !ok {
+		return "", ERR_TEMPLATE_NOT_FOUND
+	}
+	return body, nil
+}
+
+func (s *MemoryTemplateStore) Put(key, body string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.templates[key] = body
+	return nil
+}
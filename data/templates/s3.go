@@ -0,0 +1,49 @@
+package templates
+
+import (
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// S3TemplateStore is a TemplateStore backed by an S3 bucket.
+type S3TemplateStore struct {
+	client *s3.S3
+	bucket string
+}
+
+func NewS3TemplateStore(client *s3.S3, bucket string) *S3TemplateStore {
+	return &S3TemplateStore{client: client, bucket: bucket}
+}
+
+func (s *S3TemplateStore) Get(key string) (string, error) {
+	res, err := s.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+		return "", ERR_TEMPLATE_NOT_FOUND
+	}
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	buf := new(strings.Builder)
+	_, err = io.Copy(buf, res.Body)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func (s *S3TemplateStore) Put(key, body string) error {
+	_, err := s.client.PutObject(&s3.PutObjectInput{
+		Body:   strings.NewReader(body),
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
@@ -0,0 +1,13 @@
+package templates
+
+import "gjhr.me/newsletter/utils/consterror"
+
+const (
+	ERR_TEMPLATE_NOT_FOUND = consterror.ConstError("Template not found")
+)
+
+// TemplateStore persists email templates referenced by queued mail.
+type TemplateStore interface {
+	Get(key string) (string, error)
+	Put(key, body string) error
+}
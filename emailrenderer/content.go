@@ -0,0 +1,221 @@
+package emailrenderer
+
+import (
+	"bytes"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Attributes holding a single URL, by element.
+var urlAttributes = map[string][]string{
+	"a":          {"href"},
+	"area":       {"href"},
+	"audio":      {"src"},
+	"blockquote": {"cite"},
+	"del":        {"cite"},
+	"img":        {"src"},
+	"ins":        {"cite"},
+	"link":       {"href"},
+	"q":          {"cite"},
+	"source":     {"src"},
+	"table":      {"background"},
+	"td":         {"background"},
+	"th":         {"background"},
+	"track":      {"src"},
+	"video":      {"src", "poster"},
+}
+
+// Elements which do nothing, or nothing good, in an email.
+var unsafeElements = []string{"script", "iframe", "form", "input", "button", "select", "textarea"}
+
+// ResolveURLs makes every relative URL in the document absolute against base.
+// Candidates in srcset attributes which cannot be resolved are dropped.
+func (r *Renderer) ResolveURLs(base *url.URL) {
+	mutateNodes(r.doc, func(n *html.Node) { resolveURLs(n, base) })
+}
+
+// SetImageDefaults gives images an alt text if they have none and keeps them
+// within content maxWidth pixels wide. Outlook ignores max-width, so images
+// of unknown size or wider than the content are given a width attribute of
+// maxWidth, their height scaling with it.
+func (r *Renderer) SetImageDefaults(maxWidth int) {
+	mutateNodes(r.doc, func(n *html.Node) {
+		if !isElement(n, "img") {
+			return
+		}
+		findOrCreateAttribute(n, "alt")
+		width, known := imageWidth(n)
+		switch {
+		case known && width <= maxWidth:
+			return
+		case !known && getAttribute(n, "height") != "":
+			// Sized by its height, widening it would distort it
+		default:
+			findOrCreateAttribute(n, "width").Val = strconv.Itoa(maxWidth)
+			removeAttribute(n, "height")
+		}
+		style := findOrCreateAttribute(n, "style")
+		style.Val = strings.TrimSuffix(style.Val, ";")
+		if style.Val != "" {
+			style.Val += ";"
+		}
+		style.Val += "max-width:100%;height:auto"
+	})
+}
+
+// imageWidth returns the width of an image in pixels, from its width
+// attribute or style. Widths in other units are treated as known and fitting,
+// so they are left be.
+func imageWidth(n *html.Node) (int, bool) {
+	value := getAttribute(n, "width")
+	for _, decl := range parseDeclarations(getAttribute(n, "style")) {
+		if decl.property == "width" {
+			value = decl.value
+		}
+	}
+	value = strings.TrimSpace(value)
+	if value == "" || value == "auto" {
+		return 0, false
+	}
+	width, err := strconv.Atoi(strings.TrimSuffix(value, "px"))
+	if err != nil {
+		return 0, true
+	}
+	return width, true
+}
+
+// StripUnsafe removes scripts, frames and forms from the document.
+func (r *Renderer) StripUnsafe() {
+	removeNodes(r.doc, func(n *html.Node) bool {
+		return n.Type == html.ElementNode && doAny(unsafeElements, func(e string) bool { return n.Data == e })
+	})
+}
+
+// RewriteLinks replaces the href of every link with the result of f, which is
+// given the current href. Links are left as they are when f returns false.
+func (r *Renderer) RewriteLinks(f func(href string) (string, bool)) {
+	mutateNodes(r.doc, func(n *html.Node) {
+		if !isElement(n, "a") {
+			return
+		}
+		for i := range n.Attr {
+			if n.Attr[i].Key != "href" {
+				continue
+			}
+			if rewritten, ok := f(n.Attr[i].Val); ok {
+				n.Attr[i].Val = rewritten
+			}
+		}
+	})
+}
+
+// AppendPixel adds an invisible 1x1 image with the given source to the end of
+// the body.
+func (r *Renderer) AppendPixel(src string) {
+	var body *html.Node
+	mutateNodes(r.doc, func(n *html.Node) {
+		if body == nil && isElement(n, "body") {
+			body = n
+		}
+	})
+	if body == nil {
+		return
+	}
+	body.AppendChild(&html.Node{
+		Type:     html.ElementNode,
+		Data:     "img",
+		DataAtom: atom.Img,
+		Attr: []html.Attribute{
+			{Key: "src", Val: src},
+			{Key: "alt", Val: ""},
+			{Key: "width", Val: "1"},
+			{Key: "height", Val: "1"},
+			{Key: "style", Val: "display:block;width:1px;height:1px;border:0"},
+		},
+	})
+}
+
+// BodyString renders only the contents of the body, for documents parsed from
+// HTML fragments.
+func (r *Renderer) BodyString() (string, error) {
+	var body *html.Node
+	mutateNodes(r.doc, func(n *html.Node) {
+		if body == nil && isElement(n, "body") {
+			body = n
+		}
+	})
+	if body == nil {
+		return "", nil
+	}
+	var writer bytes.Buffer
+	for child := body.FirstChild; child != nil; child = child.NextSibling {
+		err := html.Render(&writer, child)
+		if err != nil {
+			return "", err
+		}
+	}
+	return writer.String(), nil
+}
+
+func resolveURLs(node *html.Node, base *url.URL) {
+	if node.Type != html.ElementNode {
+		return
+	}
+	for i := range node.Attr {
+		attr := &node.Attr[i]
+		switch {
+		case attr.Key == "srcset":
+			attr.Val = resolveSrcset(attr.Val, base)
+		case doAny(urlAttributes[node.Data], func(key string) bool { return key == attr.Key }):
+			if resolved, ok := resolveURL(attr.Val, base); ok {
+				attr.Val = resolved
+			}
+		}
+	}
+	// Drop srcset entirely when none of its candidates survived
+	attrs := node.Attr[:0]
+	for _, attr := range node.Attr {
+		if attr.Key != "srcset" || attr.Val != "" {
+			attrs = append(attrs, attr)
+		}
+	}
+	node.Attr = attrs
+}
+
+func resolveURL(ref string, base *url.URL) (string, bool) {
+	ref = strings.TrimSpace(ref)
+	parsed, err := url.Parse(ref)
+	if err != nil {
+		return "", false
+	}
+	// Leave in-document links alone
+	if ref == "" || strings.HasPrefix(ref, "#") {
+		return ref, true
+	}
+	return base.ResolveReference(parsed).String(), true
+}
+
+func resolveSrcset(srcset string, base *url.URL) string {
+	candidates := []string{}
+	for _, candidate := range strings.Split(srcset, ",") {
+		fields := strings.Fields(candidate)
+		if len(fields) == 0 {
+			continue
+		}
+		resolved, ok := resolveURL(fields[0], base)
+		if !ok || resolved == "" {
+			continue
+		}
+		parsed, _ := url.Parse(resolved)
+		if parsed.Scheme != "http" && parsed.Scheme != "https" {
+			continue
+		}
+		fields[0] = resolved
+		candidates = append(candidates, strings.Join(fields, " "))
+	}
+	return strings.Join(candidates, ", ")
+}
@@ -0,0 +1,129 @@
+package emailrenderer
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func renderBody(t *testing.T, r *Renderer) string {
+	t.Helper()
+	body, err := r.BodyString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func newRenderer(t *testing.T, doc string) *Renderer {
+	t.Helper()
+	r, err := NewRenderer(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestSetImageDefaults(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			"unknown size",
+			`<img src="a.png">`,
+			`<img src="a.png" alt="" width="600" style="max-width:100%;height:auto"/>`,
+		},
+		{
+			"too wide",
+			`<img src="a.png" alt="A" width="1200" height="800">`,
+			`<img src="a.png" alt="A" width="600" style="max-width:100%;height:auto"/>`,
+		},
+		{
+			"too wide by style",
+			`<img src="a.png" style="width:900px">`,
+			`<img src="a.png" style="width:900px;max-width:100%;height:auto" alt="" width="600"/>`,
+		},
+		{
+			"fits",
+			`<img src="a.png" width="16" height="16">`,
+			`<img src="a.png" width="16" height="16" alt=""/>`,
+		},
+		{
+			"relative width",
+			`<img src="a.png" width="50%">`,
+			`<img src="a.png" width="50%" alt=""/>`,
+		},
+		{
+			"only height",
+			`<img src="a.png" height="20">`,
+			`<img src="a.png" height="20" alt="" style="max-width:100%;height:auto"/>`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newRenderer(t, test.in)
+			r.SetImageDefaults(600)
+			if got := renderBody(t, r); got != test.want {
+				t.Errorf("got  %v\nwant %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestResolveURL(t *testing.T) {
+	base, _ := url.Parse("https://example.com/blog/post/")
+	tests := []struct {
+		ref  string
+		want string
+		ok   bool
+	}{
+		{"image.png", "https://example.com/blog/post/image.png", true},
+		{"../other/", "https://example.com/blog/other/", true},
+		{"/about", "https://example.com/about", true},
+		{"//cdn.example.net/a.png", "https://cdn.example.net/a.png", true},
+		{"?page=2", "https://example.com/blog/post/?page=2", true},
+		{"http://other.example/x", "http://other.example/x", true},
+		{"mailto:editor@example.com", "mailto:editor@example.com", true},
+		{"  spaced.png  ", "https://example.com/blog/post/spaced.png", true},
+		{"#section", "#section", true},
+		{"", "", true},
+		{"http://[::1", "", false},
+	}
+	for _, test := range tests {
+		got, ok := resolveURL(test.ref, base)
+		if got != test.want || ok != test.ok {
+			t.Errorf("resolveURL(%q) = %q, %v, want %q, %v", test.ref, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestResolveURLs(t *testing.T) {
+	base, _ := url.Parse("https://example.com/blog/post")
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"link", `<a href="next">Next</a>`, `<a href="https://example.com/blog/next">Next</a>`},
+		{"image", `<img src="/a.png" alt=""/>`, `<img src="https://example.com/a.png" alt=""/>`},
+		{"video poster", `<video src="v.mp4" poster="p.png"></video>`, `<video src="https://example.com/blog/v.mp4" poster="https://example.com/blog/p.png"></video>`},
+		{"quote cite", `<blockquote cite="source">Q</blockquote>`, `<blockquote cite="https://example.com/blog/source">Q</blockquote>`},
+		{"table background", `<table background="bg.png"></table>`, `<table background="https://example.com/blog/bg.png"></table>`},
+		{"other attributes", `<a href="a" title="b">A</a>`, `<a href="https://example.com/blog/a" title="b">A</a>`},
+		{"attributes of other elements", `<div src="a"></div>`, `<div src="a"></div>`},
+		{"srcset", `<img src="a.png" srcset="a.png 1x, /b.png 2x" alt=""/>`, `<img src="https://example.com/blog/a.png" srcset="https://example.com/blog/a.png 1x, https://example.com/b.png 2x" alt=""/>`},
+		{"srcset drops other schemes", `<img src="a.png" srcset="ftp://x/a.png 1x, b.png 2x" alt=""/>`, `<img src="https://example.com/blog/a.png" srcset="https://example.com/blog/b.png 2x" alt=""/>`},
+		{"srcset dropped when empty", `<img src="a.png" srcset="javascript:x 1x" alt=""/>`, `<img src="https://example.com/blog/a.png" alt=""/>`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newRenderer(t, test.in)
+			r.ResolveURLs(base)
+			if got := renderBody(t, r); got != test.want {
+				t.Errorf("got  %v\nwant %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+package emailrenderer
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// InlineCSS applies the document's style sheets to the style attributes of
+// the elements they match, as many email clients drop <style> blocks.
+// Selectors that cannot be applied statically (pseudo-classes and elements)
+// and at-rules such as media queries are kept in a single <style> in the head.
+func (r *Renderer) InlineCSS() {
+	styles := []*html.Node{}
+	mutateNodes(r.doc, func(n *html.Node) {
+		if isElement(n, "style") {
+			styles = append(styles, n)
+		}
+	})
+	if len(styles) == 0 {
+		return
+	}
+
+	rules := []cssRule{}
+	preserved := []string{}
+	for _, style := range styles {
+		sheet := ""
+		for child := style.FirstChild; child != nil; child = child.NextSibling {
+			sheet += child.Data
+		}
+		parsedRules, parsedPreserved := parseStyleSheet(sheet)
+		rules = append(rules, parsedRules...)
+		preserved = append(preserved, parsedPreserved...)
+		style.Parent.RemoveChild(style)
+	}
+
+	// Collect matching declarations for every element
+	applied := map[*html.Node][]cssDeclaration{}
+	order := 0
+	for _, rule := range rules {
+		for _, sel := range splitSelectors(rule.selectors) {
+			if hasPseudo(sel) {
+				preserved = append(preserved, sel+"{"+rule.declarations+"}")
+				continue
+			}
+			compiled, err := cascadia.Parse(sel)
+			if err != nil {
+				preserved = append(preserved, sel+"{"+rule.declarations+"}")
+				continue
+			}
+			specificity := compiled.Specificity()
+			for _, n := range cascadia.QueryAll(r.doc, compiled) {
+				for _, decl := range parseDeclarations(rule.declarations) {
+					decl.specificity = specificity
+					decl.order = order
+					order++
+					applied[n] = append(applied[n], decl)
+				}
+			}
+		}
+	}
+
+	for n, decls := range applied {
+		style := findOrCreateAttribute(n, "style")
+		for _, decl := range parseDeclarations(style.Val) {
+			decl.inline = true
+			decl.order = order
+			order++
+			decls = append(decls, decl)
+		}
+		style.Val = formatDeclarations(cascade(decls))
+	}
+
+	if len(preserved) > 0 {
+		head := findOrCreateHead(r.doc)
+		style := &html.Node{Type: html.ElementNode, Data: "style", DataAtom: atom.Style}
+		style.AppendChild(&html.Node{Type: html.TextNode, Data: strings.Join(preserved, "\n")})
+		head.AppendChild(style)
+	}
+}
+
+type cssRule struct {
+	selectors    string
+	declarations string
+}
+
+type cssDeclaration struct {
+	property    string
+	value       string
+	important   bool
+	inline      bool
+	specificity cascadia.Specificity
+	order       int
+}
+
+// less orders declarations by cascade precedence.
+func (d cssDeclaration) less(other cssDeclaration) bool {
+	if d.important != other.important {
+		return other.important
+	}
+	if d.inline != other.inline {
+		return other.inline
+	}
+	if d.specificity != other.specificity {
+		return d.specificity.Less(other.specificity)
+	}
+	return d.order < other.order
+}
+
+// cascade keeps the winning declaration of each property, ordered from lowest
+// to highest precedence so shorthands and longhands still resolve correctly.
+func cascade(decls []cssDeclaration) []cssDeclaration {
+	winners := map[string]cssDeclaration{}
+	for _, decl := range decls {
+		if current, ok := winners[decl.property]; !ok || current.less(decl) {
+			winners[decl.property] = decl
+		}
+	}
+	result := make([]cssDeclaration, 0, len(winners))
+	for _, decl := range winners {
+		result = append(result, decl)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].less(result[j]) })
+	return result
+}
+
+func formatDeclarations(decls []cssDeclaration) string {
+	parts := make([]string, 0, len(decls))
+	for _, decl := range decls {
+		part := decl.property + ":" + decl.value
+		if decl.important {
+			part += " !important"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ";")
+}
+
+// parseStyleSheet splits a style sheet into plain rules and at-rules, which
+// are returned unparsed.
+func parseStyleSheet(sheet string) (rules []cssRule, atRules []string) {
+	sheet = stripComments(sheet)
+	for {
+		sheet = strings.TrimSpace(sheet)
+		if sheet == "" {
+			return
+		}
+		if sheet[0] == '@' {
+			end := strings.IndexAny(sheet, ";{")
+			if end < 0 {
+				atRules = append(atRules, sheet)
+				return
+			}
+			if sheet[end] == ';' {
+				atRules = append(atRules, sheet[:end+1])
+				sheet = sheet[end+1:]
+				continue
+			}
+			close := matchingBrace(sheet, end)
+			atRules = append(atRules, sheet[:close+1])
+			sheet = sheet[close+1:]
+			continue
+		}
+		open := strings.IndexByte(sheet, '{')
+		if open < 0 {
+			return
+		}
+		close := matchingBrace(sheet, open)
+		rules = append(rules, cssRule{
+			selectors:    strings.TrimSpace(sheet[:open]),
+			declarations: strings.TrimSpace(sheet[open+1 : close]),
+		})
+		sheet = sheet[close+1:]
+	}
+}
+
+func parseDeclarations(block string) []cssDeclaration {
+	decls := []cssDeclaration{}
+	for _, part := range splitOutside(block, ';') {
+		property, value, found := strings.Cut(part, ":")
+		if !found {
+			continue
+		}
+		property = strings.ToLower(strings.TrimSpace(property))
+		value = strings.TrimSpace(value)
+		important := false
+		if i := strings.Index(strings.ToLower(value), "!important"); i >= 0 {
+			important = true
+			value = strings.TrimSpace(value[:i])
+		}
+		if property == "" || value == "" {
+			continue
+		}
+		decls = append(decls, cssDeclaration{property: property, value: value, important: important})
+	}
+	return decls
+}
+
+func splitSelectors(selectors string) []string {
+	result := []string{}
+	for _, sel := range splitOutside(selectors, ',') {
+		sel = strings.TrimSpace(sel)
+		if sel != "" {
+			result = append(result, sel)
+		}
+	}
+	return result
+}
+
+// hasPseudo reports whether a selector has a pseudo-class or pseudo-element,
+// ignoring colons in attribute selectors such as a[href^="http:"] and escaped
+// in class names such as .md\:flex.
+func hasPseudo(sel string) bool {
+	return len(splitOutside(sel, ':')) > 1
+}
+
+// splitOutside splits s on sep where it is not escaped or inside quotes,
+// parentheses or brackets, e.g. in url(data:...;base64,...) or [title="a,b"].
+func splitOutside(s string, sep byte) []string {
+	parts := []string{}
+	depth := 0
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\\':
+			i++
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '(' || c == '[':
+			depth++
+		case c == ')' || c == ']':
+			depth--
+		case c == sep && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// matchingBrace returns the index of the brace closing the one at open, or
+// the end of s if it is never closed.
+func matchingBrace(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(s) - 1
+}
+
+func stripComments(s string) string {
+	for {
+		start := strings.Index(s, "/*")
+		if start < 0 {
+			return s
+		}
+		end := strings.Index(s[start+2:], "*/")
+		if end < 0 {
+			return s[:start]
+		}
+		s = s[:start] + s[start+2+end+2:]
+	}
+}
+
+func findOrCreateHead(doc *html.Node) *html.Node {
+	var head *html.Node
+	mutateNodes(doc, func(n *html.Node) {
+		if head == nil && isElement(n, "head") {
+			head = n
+		}
+	})
+	if head != nil {
+		return head
+	}
+	head = &html.Node{Type: html.ElementNode, Data: "head", DataAtom: atom.Head}
+	var root *html.Node
+	mutateNodes(doc, func(n *html.Node) {
+		if root == nil && isElement(n, "html") {
+			root = n
+		}
+	})
+	if root == nil {
+		root = doc
+	}
+	root.InsertBefore(head, root.FirstChild)
+	return head
+}
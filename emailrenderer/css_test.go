@@ -0,0 +1,111 @@
+package emailrenderer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/cascadia"
+)
+
+// styleOf returns the style attribute of the element with the given id.
+func styleOf(t *testing.T, r *Renderer, id string) string {
+	t.Helper()
+	n := cascadia.Query(r.doc, cascadia.MustCompile("#"+id))
+	if n == nil {
+		t.Fatalf("no element #%v", id)
+	}
+	return getAttribute(n, "style")
+}
+
+func TestInlineCSSCascade(t *testing.T) {
+	tests := []struct {
+		name   string
+		sheet  string
+		inline string
+		want   string
+	}{
+		{"later rule wins", `p{color:red} p{color:blue}`, "", "color:blue"},
+		{"class beats type", `.a{color:blue} p{color:red}`, "", "color:blue"},
+		{"id beats class", `#t{color:green} .a{color:blue}`, "", "color:green"},
+		{"two classes beat type and class", `.a.b{color:blue} p.a{color:red}`, "", "color:blue"},
+		{"inline beats id", `#t{color:green}`, "color:black", "color:black"},
+		{"important beats inline", `p{color:red !important}`, "color:black", "color:red !important"},
+		{"important beats specificity", `p{color:red !important} #t{color:green}`, "", "color:red !important"},
+		{"inline important beats important", `#t{color:red !important}`, "color:black !important", "color:black !important"},
+		{"keeps other properties", `p{margin:0} .a{color:blue}`, "padding:1px", "margin:0;color:blue;padding:1px"},
+		{"longhand after shorthand", `p{margin:0} .a{margin-top:10px}`, "", "margin:0;margin-top:10px"},
+		{"shorthand by precedence", `.a{margin-top:10px} p{margin:0}`, "", "margin:0;margin-top:10px"},
+		{"selector lists", `h1, p{color:red}`, "", "color:red"},
+		{"ignores comments", `/* p{color:red} */ p{color:blue}`, "", "color:blue"},
+		{"not matching", `div{color:red}`, "", ""},
+		{"keeps media queries", `@media (max-width:600px){p{color:red}}`, "", ""},
+		{"data urls", `p{background:url(data:image/png;base64,AA==)}`, "", "background:url(data:image/png;base64,AA==)"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			style := ""
+			if test.inline != "" {
+				style = ` style="` + test.inline + `"`
+			}
+			r := newRenderer(t, `<html><head><style>`+test.sheet+`</style></head><body><p id="t" class="a b"`+style+`>x</p></body></html>`)
+			r.InlineCSS()
+			if got := styleOf(t, r, "t"); got != test.want {
+				t.Errorf("got style %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestInlineCSSKeepsRulesThatCannotBeInlined(t *testing.T) {
+	r := newRenderer(t, `<html><head><style>@media (max-width:600px){p{color:red}} p{color:blue} p::first-line{font-weight:bold}</style></head><body><p>x</p></body></html>`)
+	r.InlineCSS()
+	doc, err := r.String()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "<style>@media (max-width:600px){p{color:red}}\np::first-line{font-weight:bold}</style>"
+	if !strings.Contains(doc, want) {
+		t.Errorf("got %v, want it to contain %v", doc, want)
+	}
+	if strings.Count(doc, "<style>") != 1 {
+		t.Errorf("want a single style element: %v", doc)
+	}
+}
+
+func TestHasPseudo(t *testing.T) {
+	tests := []struct {
+		sel  string
+		want bool
+	}{
+		{"a", false},
+		{"a:hover", true},
+		{"p::first-line", true},
+		{"li:not(.first)", true},
+		{`a[href^="http:"]`, false},
+		{`a[href^='mailto:']`, false},
+		{`a[href^=http\:]`, false},
+		{`a[href^="http:"]:hover`, true},
+		{`.md\:flex`, false},
+		{`[data-label="a\"b:c"]`, false},
+	}
+	for _, test := range tests {
+		if got := hasPseudo(test.sel); got != test.want {
+			t.Errorf("hasPseudo(%q) = %v, want %v", test.sel, got, test.want)
+		}
+	}
+}
+
+func TestInlineCSSAttributeSelectorWithColon(t *testing.T) {
+	r := newRenderer(t, `<html><head><style>a[href^="http:"]{color:red} a:hover{color:blue}</style></head><body><a href="http://example.com">x</a></body></html>`)
+	r.InlineCSS()
+	doc, err := r.String()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(doc, `<a href="http://example.com" style="color:red">`) {
+		t.Errorf("attribute selector was not inlined: %v", doc)
+	}
+	if !strings.Contains(doc, `a:hover{color:blue}`) || strings.Contains(doc, `a[href^="http:"]{`) {
+		t.Errorf("only the pseudo-class rule should be kept in the head: %v", doc)
+	}
+}
@@ -2,7 +2,9 @@ package emailrenderer
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"strings"
 
 	"golang.org/x/net/html"
 )
@@ -49,15 +51,68 @@ func (r *Renderer) ReplaceHrefByID(mappings map[string]string) {
 	mutateNodes(r.doc, func(n *html.Node) { replaceHrefByID(n, mappings) })
 }
 
+// ReplaceInnerHTMLByID replaces the children of elements with the parsed HTML
+// fragments given for their IDs.
+func (r *Renderer) ReplaceInnerHTMLByID(mappings map[string]string) error {
+	var err error
+	mutateNodes(r.doc, func(n *html.Node) {
+		if err == nil {
+			err = replaceInnerHTMLByID(n, mappings)
+		}
+	})
+	return err
+}
+
+// RemoveByID removes the elements with the given IDs, and their children,
+// from the document.
+func (r *Renderer) RemoveByID(ids ...string) {
+	removeNodes(r.doc, func(n *html.Node) bool {
+		return n.Type == html.ElementNode && doAny(ids, func(id string) bool { return hasID(n, id) })
+	})
+}
+
+// RepeatByID replaces the element with the given ID by count copies of it.
+// The IDs of each copy and its descendants are suffixed with its index, such
+// as "-0", so every copy can be filled in by ID.
+func (r *Renderer) RepeatByID(id string, count int) {
+	var template *html.Node
+	mutateNodes(r.doc, func(n *html.Node) {
+		if template == nil && n.Type == html.ElementNode && hasID(n, id) {
+			template = n
+		}
+	})
+	if template == nil {
+		return
+	}
+	for i := 0; i < count; i++ {
+		c := cloneNode(template)
+		suffix := fmt.Sprintf("-%v", i)
+		mutateNodes(c, func(n *html.Node) {
+			for j := range n.Attr {
+				if n.Attr[j].Key == "id" {
+					n.Attr[j].Val += suffix
+				}
+			}
+		})
+		template.Parent.InsertBefore(c, template)
+	}
+	template.Parent.RemoveChild(template)
+}
+
+// HasID reports whether the document contains an element with the given ID.
+func (r *Renderer) HasID(id string) bool {
+	found := false
+	mutateNodes(r.doc, func(n *html.Node) { found = found || (n.Type == html.ElementNode && hasID(n, id)) })
+	return found
+}
+
 // Private mutations
 
 func replaceTextByID(node *html.Node, mappings map[string]string) {
 	if node.Type == html.ElementNode {
 		for id, text := range mappings {
 			if hasID(node, id) {
-				for child := node.FirstChild; child != nil; child = child.NextSibling {
-					node.RemoveChild(child)
-				}
+				removeChildren(node)
 				content := html.Node{
 					Type: html.TextNode,
 					Data: text,
@@ -79,6 +134,25 @@ func replaceHrefByID(node *html.Node, mappings map[string]string) {
 	}
 }
 
+func replaceInnerHTMLByID(node *html.Node, mappings map[string]string) error {
+	if node.Type != html.ElementNode {
+		return nil
+	}
+	for id, fragment := range mappings {
+		if hasID(node, id) {
+			children, err := html.ParseFragment(strings.NewReader(fragment), node)
+			if err != nil {
+				return err
+			}
+			removeChildren(node)
+			for _, child := range children {
+				node.AppendChild(child)
+			}
+		}
+	}
+	return nil
+}
+
 // Helpers
 
 func doAny[T any](s []T, f func(T) bool) bool {
@@ -117,6 +191,50 @@ func findOrCreateAttribute(n *html.Node, key string) (result *html.Attribute) {
 	return
 }
 
+func removeAttribute(n *html.Node, key string) {
+	attrs := n.Attr[:0]
+	for _, a := range n.Attr {
+		if a.Key != key {
+			attrs = append(attrs, a)
+		}
+	}
+	n.Attr = attrs
+}
+
+func cloneNode(n *html.Node) *html.Node {
+	c := &html.Node{
+		Type:      n.Type,
+		DataAtom:  n.DataAtom,
+		Data:      n.Data,
+		Namespace: n.Namespace,
+		Attr:      append([]html.Attribute(nil), n.Attr...),
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		c.AppendChild(cloneNode(child))
+	}
+	return c
+}
+
+func removeChildren(node *html.Node) {
+	for node.FirstChild != nil {
+		node.RemoveChild(node.FirstChild)
+	}
+}
+
+// removeNodes removes every node matching f. Children of removed nodes are
+// not visited.
+func removeNodes(node *html.Node, f func(*html.Node) bool) {
+	for child := node.FirstChild; child != nil; {
+		next := child.NextSibling
+		if f(child) {
+			node.RemoveChild(child)
+		} else {
+			removeNodes(child, f)
+		}
+		child = next
+	}
+}
+
 func mutateNodes(node *html.Node, f func(*html.Node)) {
 	f(node)
 	for child := node.FirstChild; child != nil; child = child.NextSibling {
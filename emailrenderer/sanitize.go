@@ -0,0 +1,172 @@
+package emailrenderer
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Policy is an allowlist of the elements, attributes and URL schemes kept by
+// Sanitize.
+type Policy struct {
+	// Attributes allowed on each allowed element, in addition to Global.
+	Elements map[string][]string
+	// Attributes allowed on every allowed element.
+	Global []string
+	// Attributes holding URLs, which must use one of URLSchemes.
+	URLAttributes []string
+	URLSchemes    []string
+	// Elements removed along with their contents. Other elements which are not
+	// allowed are replaced by their contents.
+	Drop []string
+}
+
+// ContentPolicy allows the markup commonly found in blog posts. IDs and
+// classes are dropped so content cannot collide with the layout it is placed
+// in.
+var ContentPolicy = Policy{
+	Elements: map[string][]string{
+		"a": {"href"}, "abbr": {}, "address": {}, "b": {}, "blockquote": {"cite"},
+		"br": {}, "caption": {}, "cite": {}, "code": {}, "col": {"span"},
+		"colgroup": {"span"}, "dd": {}, "del": {}, "details": {}, "dfn": {},
+		"div": {}, "dl": {}, "dt": {}, "em": {}, "figcaption": {}, "figure": {},
+		"h1": {}, "h2": {}, "h3": {}, "h4": {}, "h5": {}, "h6": {}, "hr": {},
+		"i": {}, "img": {"src", "srcset", "alt", "width", "height"}, "ins": {},
+		"kbd": {}, "li": {}, "mark": {}, "ol": {"start", "type", "reversed"},
+		"p": {}, "pre": {}, "q": {"cite"}, "s": {}, "samp": {}, "small": {},
+		"span": {}, "strong": {}, "sub": {}, "summary": {}, "sup": {},
+		"table": {"border", "cellpadding", "cellspacing", "width"}, "tbody": {},
+		"td": {"colspan", "rowspan", "width", "valign"}, "tfoot": {},
+		"th": {"colspan", "rowspan", "width", "valign", "scope"}, "thead": {},
+		"time": {"datetime"}, "tr": {}, "u": {}, "ul": {}, "var": {},
+	},
+	Global:        []string{"align", "dir", "lang", "style", "title"},
+	URLAttributes: []string{"href", "src", "cite"},
+	URLSchemes:    []string{"http", "https", "mailto"},
+	Drop: []string{
+		"applet", "audio", "base", "button", "embed", "form", "frame", "frameset",
+		"head", "iframe", "input", "link", "math", "meta", "noscript", "object",
+		"script", "select", "style", "svg", "template", "textarea", "title", "video",
+	},
+}
+
+// Style declarations which can run code in some clients.
+var unsafeStyles = []string{"expression(", "javascript:", "behavior", "-moz-binding"}
+
+// Sanitize removes everything from the body of the document which the policy
+// does not allow. Comments are always removed.
+func (r *Renderer) Sanitize(p Policy) {
+	var body *html.Node
+	mutateNodes(r.doc, func(n *html.Node) {
+		if body == nil && isElement(n, "body") {
+			body = n
+		}
+	})
+	if body != nil {
+		sanitizeChildren(body, p)
+	}
+}
+
+// EscapeTemplateText rewrites the opening delimiters of template actions in s
+// into actions printing them, so s is output as-is when later executed as a
+// html/template rather than being run. Raw strings are used as quotes would be
+// escaped when the document is rendered.
+//
+// Escape serialized HTML rather than the text of single nodes, as removing
+// comments and elements joins neighbouring text into new delimiters.
+func EscapeTemplateText(s string) string {
+	return strings.ReplaceAll(s, "{{", "{{`{{`}}")
+}
+
+func sanitizeChildren(node *html.Node, p Policy) {
+	for child := node.FirstChild; child != nil; {
+		next := child.NextSibling
+		switch child.Type {
+		case html.CommentNode, html.DoctypeNode:
+			node.RemoveChild(child)
+		case html.ElementNode:
+			allowed, ok := p.Elements[child.Data]
+			switch {
+			case doAny(p.Drop, func(e string) bool { return e == child.Data }):
+				node.RemoveChild(child)
+			case !ok:
+				// Replace by the children, which are then visited in its place
+				first := child.FirstChild
+				for child.FirstChild != nil {
+					grandchild := child.FirstChild
+					child.RemoveChild(grandchild)
+					node.InsertBefore(grandchild, child)
+				}
+				node.RemoveChild(child)
+				if first != nil {
+					next = first
+				}
+			default:
+				sanitizeAttributes(child, allowed, p)
+				sanitizeChildren(child, p)
+			}
+		}
+		child = next
+	}
+}
+
+func sanitizeAttributes(n *html.Node, allowed []string, p Policy) {
+	attrs := n.Attr[:0]
+	for _, attr := range n.Attr {
+		isAllowed := func(key string) bool { return key == attr.Key }
+		if attr.Namespace != "" || !(doAny(allowed, isAllowed) || doAny(p.Global, isAllowed)) {
+			continue
+		}
+		if doAny(p.URLAttributes, isAllowed) && !allowedURL(attr.Val, p) {
+			continue
+		}
+		if attr.Key == "srcset" {
+			attr.Val = sanitizeSrcset(attr.Val, p)
+			if attr.Val == "" {
+				continue
+			}
+		}
+		if attr.Key == "style" {
+			attr.Val = sanitizeStyle(attr.Val)
+			if attr.Val == "" {
+				continue
+			}
+		}
+		attrs = append(attrs, attr)
+	}
+	n.Attr = attrs
+}
+
+// allowedURL reports whether a URL uses an allowed scheme. Relative URLs are
+// allowed, they should be resolved beforehand.
+func allowedURL(ref string, p Policy) bool {
+	parsed, err := url.Parse(strings.TrimSpace(ref))
+	if err != nil {
+		return false
+	}
+	return parsed.Scheme == "" || doAny(p.URLSchemes, func(s string) bool { return strings.EqualFold(s, parsed.Scheme) })
+}
+
+func sanitizeSrcset(srcset string, p Policy) string {
+	candidates := []string{}
+	for _, candidate := range strings.Split(srcset, ",") {
+		fields := strings.Fields(candidate)
+		if len(fields) > 0 && allowedURL(fields[0], p) {
+			candidates = append(candidates, strings.Join(fields, " "))
+		}
+	}
+	return strings.Join(candidates, ", ")
+}
+
+func sanitizeStyle(style string) string {
+	decls := []string{}
+	for _, decl := range splitOutside(style, ';') {
+		lower := strings.ToLower(decl)
+		if strings.TrimSpace(decl) == "" || doAny(unsafeStyles, func(s string) bool { return strings.Contains(lower, s) }) {
+			continue
+		}
+		decls = append(decls, strings.TrimSpace(decl))
+	}
+	return strings.Join(decls, ";")
+}
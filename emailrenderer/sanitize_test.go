@@ -0,0 +1,83 @@
+package emailrenderer
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"allowed markup", `<p>A <strong>b</strong> <a href="https://example.com">c</a></p>`, `<p>A <strong>b</strong> <a href="https://example.com">c</a></p>`},
+		{"drops scripts with contents", `<p>A</p><script>alert(1)</script>`, `<p>A</p>`},
+		{"drops styles with contents", `<style>p{}</style><p>A</p>`, `<p>A</p>`},
+		{"unwraps unknown elements", `<p><font color="red">A <b>b</b></font></p>`, `<p>A <b>b</b></p>`},
+		{"unwraps nested unknown elements", `<center><font>A</font></center>`, `A`},
+		{"removes comments", `<p>A<!-- x -->B</p>`, `<p>AB</p>`},
+		{"drops ids and classes", `<p id="x" class="y" title="z">A</p>`, `<p title="z">A</p>`},
+		{"drops event handlers", `<img src="a.png" onerror="alert(1)" alt="A"/>`, `<img src="a.png" alt="A"/>`},
+		{"drops javascript links", `<a href="javascript:alert(1)">A</a>`, `<a>A</a>`},
+		{"drops javascript links by case", `<a href="JavaScript:alert(1)">A</a>`, `<a>A</a>`},
+		{"keeps mailto links", `<a href="mailto:a@example.com">A</a>`, `<a href="mailto:a@example.com">A</a>`},
+		{"keeps relative links", `<a href="/a">A</a>`, `<a href="/a">A</a>`},
+		{"drops data images", `<img src="data:image/png;base64,AA==" alt=""/>`, `<img alt=""/>`},
+		{"filters srcset", `<img srcset="https://example.com/a.png 1x, javascript:x 2x" alt=""/>`, `<img srcset="https://example.com/a.png 1x" alt=""/>`},
+		{"drops empty srcset", `<img srcset="javascript:x 2x" alt=""/>`, `<img alt=""/>`},
+		{"filters styles", `<p style="color:red; width:expression(alert(1)); background:url(javascript:x)">A</p>`, `<p style="color:red">A</p>`},
+		{"drops empty styles", `<p style="behavior:url(x.htc)">A</p>`, `<p>A</p>`},
+		{"table attributes", `<table width="100"><tbody><tr><td colspan="2" onclick="x">A</td></tr></tbody></table>`, `<table width="100"><tbody><tr><td colspan="2">A</td></tr></tbody></table>`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newRenderer(t, test.in)
+			r.Sanitize(ContentPolicy)
+			if got := renderBody(t, r); got != test.want {
+				t.Errorf("got  %v\nwant %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestEscapeTemplateText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"no actions", `<p>Hello</p>`, `<p>Hello</p>`},
+		{"action", `<p>{{ .Email }}</p>`, `<p>{{ .Email }}</p>`},
+		{"unclosed action", `<p>{{ .Email</p>`, `<p>{{ .Email</p>`},
+		{"closing delimiter only", `<p>a }} b</p>`, `<p>a }} b</p>`},
+		{"three braces", `<p>{{{ .Email }}}</p>`, `<p>{{{ .Email }}}</p>`},
+		{"raw string in action", "<p>{{ `x` }}</p>", "<p>{{ `x` }}</p>"},
+		{"joined by sanitizing", `<p>a {<!-- x -->{ .Email }} b</p>`, `<p>a {{ .Email }} b</p>`},
+		// Printed braces are then percent encoded in the URL
+		{"action in attribute", `<p><a href="https://example.com/?q={{ .Email }}">a</a></p>`, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := newRenderer(t, test.in)
+			r.Sanitize(ContentPolicy)
+			content := renderBody(t, r)
+			tmpl, err := template.New("body").Parse(EscapeTemplateText(content))
+			if err != nil {
+				t.Fatalf("escaped content does not parse as a template: %v", err)
+			}
+			var out strings.Builder
+			err = tmpl.Execute(&out, struct{ Email string }{"subscriber@example.com"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(out.String(), "subscriber@example.com") {
+				t.Errorf("template action was run: %v", out.String())
+			}
+			if test.want != "" && out.String() != test.want {
+				t.Errorf("got  %v\nwant %v", out.String(), test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package emailrenderer
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// Text renders the document as plain text for a text/plain alternative part.
+// Links become numbered footnotes, headings are underlined and images are
+// replaced by their alt text.
+func (r *Renderer) Text() string {
+	w := &textWriter{}
+	w.node(r.doc)
+	text := strings.TrimSpace(w.sb.String())
+	if len(w.links) > 0 {
+		text += "\n\n"
+		for i, link := range w.links {
+			text += fmt.Sprintf("[%v] %v\n", i+1, link)
+		}
+	}
+	return strings.TrimRight(text, "\n") + "\n"
+}
+
+var skippedElements = map[string]bool{
+	"head": true, "script": true, "style": true, "title": true, "noscript": true, "template": true,
+}
+
+// Number of line breaks surrounding block elements
+var blockElements = map[string]int{
+	"p": 2, "ul": 2, "ol": 2, "table": 2, "blockquote": 2, "pre": 2, "figure": 2, "hr": 2,
+	"div": 1, "li": 1, "tr": 1, "section": 1, "article": 1, "header": 1, "footer": 1,
+	"main": 1, "nav": 1, "aside": 1, "figcaption": 1, "dl": 1, "dt": 1, "dd": 1,
+	"h1": 2, "h2": 2, "h3": 2, "h4": 2, "h5": 2, "h6": 2,
+}
+
+type textWriter struct {
+	sb              strings.Builder
+	links           []string
+	pendingNewlines int
+	pendingSpace    bool
+	preDepth        int
+}
+
+func (w *textWriter) node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.text(n.Data)
+		return
+	case html.ElementNode:
+		if skippedElements[n.Data] {
+			return
+		}
+	case html.CommentNode:
+		return
+	}
+
+	if n.Type != html.ElementNode {
+		w.children(n)
+		return
+	}
+
+	breaks := blockElements[n.Data]
+	w.block(breaks)
+	switch n.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		w.heading(n)
+	case "a":
+		w.link(n)
+	case "img":
+		alt := strings.TrimSpace(getAttribute(n, "alt"))
+		if alt != "" {
+			w.text("[" + alt + "]")
+		}
+	case "br":
+		w.flush()
+		w.sb.WriteString("\n")
+	case "hr":
+		w.flush()
+		w.sb.WriteString("----------")
+	case "li":
+		w.flush()
+		w.sb.WriteString("* ")
+		w.children(n)
+	case "td", "th":
+		w.children(n)
+		w.pendingSpace = true
+	case "pre":
+		w.preDepth++
+		w.children(n)
+		w.preDepth--
+	default:
+		w.children(n)
+	}
+	w.block(breaks)
+}
+
+func (w *textWriter) children(n *html.Node) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		w.node(child)
+	}
+}
+
+func (w *textWriter) heading(n *html.Node) {
+	w.flush()
+	start := w.sb.Len()
+	w.children(n)
+	length := utf8.RuneCountInString(w.sb.String()[start:])
+	if length == 0 {
+		return
+	}
+	underline := "-"
+	if n.Data == "h1" {
+		underline = "="
+	}
+	w.sb.WriteString("\n")
+	w.sb.WriteString(strings.Repeat(underline, length))
+}
+
+func (w *textWriter) link(n *html.Node) {
+	start := w.sb.Len()
+	w.children(n)
+	label := strings.TrimSpace(w.sb.String()[start:])
+	href := strings.TrimSpace(getAttribute(n, "href"))
+	if href == "" || strings.HasPrefix(href, "#") || href == label {
+		return
+	}
+	if label == "" {
+		w.text(href)
+		return
+	}
+	w.links = append(w.links, href)
+	w.sb.WriteString(fmt.Sprintf(" [%v]", len(w.links)))
+}
+
+func (w *textWriter) text(s string) {
+	if w.preDepth > 0 {
+		w.flush()
+		w.sb.WriteString(s)
+		return
+	}
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		if s != "" {
+			w.pendingSpace = true
+		}
+		return
+	}
+	if s[0] == ' ' || s[0] == '\n' || s[0] == '\t' || s[0] == '\r' {
+		w.pendingSpace = true
+	}
+	w.flush()
+	w.sb.WriteString(strings.Join(words, " "))
+	last := s[len(s)-1]
+	w.pendingSpace = last == ' ' || last == '\n' || last == '\t' || last == '\r'
+}
+
+// block requests that the next output starts after the given number of line
+// breaks. Breaks are never written at the start of the output.
+func (w *textWriter) block(breaks int) {
+	if breaks > w.pendingNewlines && w.sb.Len() > 0 {
+		w.pendingNewlines = breaks
+	}
+}
+
+func (w *textWriter) flush() {
+	if w.pendingNewlines > 0 {
+		current := strings.TrimRight(w.sb.String(), " ")
+		existing := len(current) - len(strings.TrimRight(current, "\n"))
+		w.sb.Reset()
+		w.sb.WriteString(current)
+		for i := existing; i < w.pendingNewlines; i++ {
+			w.sb.WriteString("\n")
+		}
+		w.pendingNewlines = 0
+		w.pendingSpace = false
+		return
+	}
+	if w.pendingSpace && w.sb.Len() > 0 {
+		if s := w.sb.String(); s[len(s)-1] != '\n' && s[len(s)-1] != ' ' {
+			w.sb.WriteString(" ")
+		}
+	}
+	w.pendingSpace = false
+}
+
+func getAttribute(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
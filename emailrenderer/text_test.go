@@ -0,0 +1,42 @@
+package emailrenderer
+
+import "testing"
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"empty", ``, "\n"},
+		{"collapses whitespace", "<p>Hello\n\t  world</p>", "Hello world\n"},
+		{"paragraphs", `<p>One</p><p>Two</p>`, "One\n\nTwo\n"},
+		{"inline elements", `<p>A <b>bold</b> <i>move</i>.</p>`, "A bold move.\n"},
+		{"divs", `<div>One</div><div>Two</div>`, "One\nTwo\n"},
+		{"line breaks", `<p>One<br>Two</p>`, "One\nTwo\n"},
+		{"h1", `<h1>Title</h1><p>Text</p>`, "Title\n=====\n\nText\n"},
+		{"h2 counts runes", `<h2>Café</h2>`, "Café\n----\n"},
+		{"empty heading", `<h2></h2><p>Text</p>`, "Text\n"},
+		{"link footnotes", `<p><a href="https://a.example">A</a> and <a href="https://b.example">B</a></p>`, "A [1] and B [2]\n\n[1] https://a.example\n[2] https://b.example\n"},
+		{"link labelled with its address", `<a href="https://a.example">https://a.example</a>`, "https://a.example\n"},
+		{"link without label", `<a href="https://a.example"></a>`, "https://a.example\n"},
+		{"anchor link", `<a href="#top">Top</a>`, "Top\n"},
+		{"image alt", `<p>See <img src="a.png" alt="a chart"></p>`, "See [a chart]\n"},
+		{"image without alt", `<p>See <img src="a.png"></p>`, "See\n"},
+		{"list", `<ul><li>One</li><li>Two</li></ul><p>After</p>`, "* One\n* Two\n\nAfter\n"},
+		{"table cells", `<table><tr><td>A</td><td>B</td></tr><tr><td>C</td><td>D</td></tr></table>`, "A B\nC D\n"},
+		{"preformatted", "<pre>a  b\n  c</pre>", "a  b\n  c\n"},
+		{"rule", `<p>A</p><hr><p>B</p>`, "A\n\n----------\n\nB\n"},
+		{"skips head, scripts and styles", `<html><head><title>T</title><style>p{}</style></head><body><script>x()</script><p>Body</p></body></html>`, "Body\n"},
+		{"skips comments", `<p>A<!-- hidden -->B</p>`, "AB\n"},
+		{"decodes entities", `<p>Fish &amp; chips &lt;3</p>`, "Fish & chips <3\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := newRenderer(t, test.in).Text()
+			if got != test.want {
+				t.Errorf("got %q, want %q", got, test.want)
+			}
+		})
+	}
+}
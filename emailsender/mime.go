@@ -0,0 +1,148 @@
+package emailsender
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gjhr.me/newsletter/utils/consterror"
+)
+
+const (
+	ERR_INVALID_HEADER_NAME  = consterror.ConstError("Header names must not be empty or contain spaces, colons or line breaks")
+	ERR_INVALID_HEADER_VALUE = consterror.ConstError("Header values must not contain line breaks")
+	ERR_HEADER_TOO_LONG      = consterror.ConstError("Header has a word too long to fold within the line length limit")
+)
+
+// Line lengths from RFC 5322 section 2.1.1, excluding the CRLF
+const (
+	foldLineLength = 78
+	maxLineLength  = 998
+)
+
+// Bytes formats the message as an RFC 5322 message suitable for SMTP or SES
+// raw sending. Messages with a text body are sent as multipart/alternative
+// with the plain text part first, as preferred by RFC 2046.
+func (msg *Message) Bytes() ([]byte, error) {
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := []Header{{"From", msg.From}, {"To", msg.To}}
+	if msg.ReplyTo != "" {
+		headers = append(headers, Header{"Reply-To", msg.ReplyTo})
+	}
+	headers = append(headers,
+		Header{"Subject", mime.QEncoding.Encode("UTF-8", msg.Subject)},
+		Header{"Date", time.Now().Format(time.RFC1123Z)},
+		Header{"Message-ID", fmt.Sprintf("<%v@%v>", uuid.NewString(), domainOf(from.Address))},
+	)
+	headers = append(headers, msg.Headers...)
+	headers = append(headers, Header{"MIME-Version", "1.0"})
+
+	var buf bytes.Buffer
+	for _, header := range headers {
+		err = writeHeader(&buf, header.Key, header.Value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if msg.Text == "" {
+		err = writeHeader(&buf, "Content-Type", "text/html; charset=UTF-8")
+		if err != nil {
+			return nil, err
+		}
+		err = writeHeader(&buf, "Content-Transfer-Encoding", "quoted-printable")
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString("\r\n")
+		err = writeQuotedPrintable(&buf, msg.Html)
+		if err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+	err = writeHeader(&buf, "Content-Type", fmt.Sprintf("multipart/alternative; boundary=%q", mw.Boundary()))
+	if err != nil {
+		return nil, err
+	}
+	buf.WriteString("\r\n")
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=UTF-8", msg.Text},
+		{"text/html; charset=UTF-8", msg.Html},
+	} {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		err = writeQuotedPrintable(pw, part.body)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = mw.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	qp := quotedprintable.NewWriter(w)
+	_, err := qp.Write([]byte(body))
+	if err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+// writeHeader writes a header field, folding it at spaces to keep lines under
+// the recommended length of RFC 5322. Line breaks in the value are rejected so
+// they cannot add headers or end the header section.
+func writeHeader(buf *bytes.Buffer, key, value string) error {
+	if key == "" || strings.ContainsAny(key, "\r\n: ") {
+		return ERR_INVALID_HEADER_NAME
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return ERR_INVALID_HEADER_VALUE
+	}
+	line := key + ":"
+	for i, word := range strings.Split(value, " ") {
+		if i > 0 && word != "" && len(line)+1+len(word) > foldLineLength {
+			buf.WriteString(line)
+			buf.WriteString("\r\n")
+			line = ""
+		}
+		line += " " + word
+		if len(line) > maxLineLength {
+			return ERR_HEADER_TOO_LONG
+		}
+	}
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+	return nil
+}
+
+func domainOf(address string) string {
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return "localhost"
+	}
+	return address[at+1:]
+}
@@ -0,0 +1,222 @@
+package emailsender
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+// parseMessage reads a formatted message back, with the bodies of its parts
+// by content type.
+func parseMessage(t *testing.T, msg *Message) (*mail.Message, map[string]string) {
+	t.Helper()
+	raw, err := msg.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bodies := map[string]string{}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		bodies[mediaType] = readQuotedPrintable(t, parsed.Body)
+		return parsed, bodies
+	}
+	mr := multipart.NewReader(parsed.Body, params["boundary"])
+	for {
+		part, err := mr.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		partType, _, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		bodies[partType] = readQuotedPrintable(t, part)
+	}
+	return parsed, bodies
+}
+
+func readQuotedPrintable(t *testing.T, r io.Reader) string {
+	t.Helper()
+	body, err := io.ReadAll(quotedprintable.NewReader(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestBytes(t *testing.T) {
+	longLine := strings.Repeat("word ", 40)
+	tests := []struct {
+		name      string
+		msg       Message
+		mediaType string
+		bodies    map[string]string
+	}{
+		{
+			"html only",
+			Message{To: "reader@example.com", From: "List <list@example.com>", Subject: "Hello", Html: "<p>" + longLine + "</p>"},
+			"text/html",
+			map[string]string{"text/html": "<p>" + longLine + "</p>"},
+		},
+		{
+			"html and text",
+			Message{To: "reader@example.com", From: "list@example.com", Subject: "Hello", Html: "<p>Café = café</p>", Text: "Café = café"},
+			"multipart/alternative",
+			map[string]string{"text/plain": "Café = café", "text/html": "<p>Café = café</p>"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			raw, err := test.msg.Bytes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, line := range strings.Split(string(raw), "\r\n") {
+				if len(line) > 78 {
+					t.Errorf("line of %v bytes: %q", len(line), line)
+				}
+			}
+			parsed, bodies := parseMessage(t, &test.msg)
+			mediaType, _, _ := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+			if mediaType != test.mediaType {
+				t.Errorf("got content type %v, want %v", mediaType, test.mediaType)
+			}
+			for contentType, want := range test.bodies {
+				if bodies[contentType] != want {
+					t.Errorf("got %v body %q, want %q", contentType, bodies[contentType], want)
+				}
+			}
+			if len(bodies) != len(test.bodies) {
+				t.Errorf("got parts %v", bodies)
+			}
+			if parsed.Header.Get("MIME-Version") != "1.0" {
+				t.Error("missing MIME-Version")
+			}
+			if !strings.HasSuffix(parsed.Header.Get("Message-ID"), "@example.com>") {
+				t.Errorf("got Message-ID %v", parsed.Header.Get("Message-ID"))
+			}
+			if _, err := parsed.Header.Date(); err != nil {
+				t.Errorf("invalid Date: %v", err)
+			}
+		})
+	}
+}
+
+func TestBytesHeaders(t *testing.T) {
+	subject := "Café news: " + strings.Repeat("über ", 30)
+	msg := &Message{
+		To:      "reader@example.com",
+		From:    "list@example.com",
+		ReplyTo: "editor@example.com",
+		Subject: subject,
+		Html:    "<p>Hi</p>",
+		Headers: append(ListHeaders("<list.example.com>", "https://list.example.com/unsubscribe?token="+strings.Repeat("t", 80)),
+			TrackingHeaders("Café list", "issue-1")...),
+	}
+	parsed, _ := parseMessage(t, msg)
+	decoder := &mime.WordDecoder{}
+
+	got, err := decoder.DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != subject {
+		t.Errorf("got subject %q, want %q", got, subject)
+	}
+	got, err = decoder.DecodeHeader(parsed.Header.Get(HEADER_LIST))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "Café list" {
+		t.Errorf("got list %q", got)
+	}
+	for key, want := range map[string]string{
+		"Reply-To":              "editor@example.com",
+		"List-Id":               "<list.example.com>",
+		"List-Unsubscribe":      "<https://list.example.com/unsubscribe?token=" + strings.Repeat("t", 80) + ">",
+		"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		HEADER_ISSUE:            "issue-1",
+	} {
+		if parsed.Header.Get(key) != want {
+			t.Errorf("got %v %q, want %q", key, parsed.Header.Get(key), want)
+		}
+	}
+}
+
+func TestBytesRejectsInvalidFrom(t *testing.T) {
+	msg := &Message{To: "reader@example.com", From: "not an address", Subject: "Hello", Html: "<p>Hi</p>"}
+	_, err := msg.Bytes()
+	if err == nil {
+		t.Error("expected an error for an invalid From address")
+	}
+}
+
+func TestWriteHeader(t *testing.T) {
+	longURL := "https://list.example.com/unsubscribe?token=" + strings.Repeat("a", 60)
+	tests := []struct {
+		name  string
+		key   string
+		value string
+		want  string
+		err   error
+	}{
+		{"short", "Subject", "Hello", "Subject: Hello\r\n", nil},
+		{"empty value", "X-Empty", "", "X-Empty: \r\n", nil},
+		{"folds at spaces", "List-Unsubscribe", "<" + longURL + ">, <mailto:unsubscribe@example.com>",
+			"List-Unsubscribe: <" + longURL + ">,\r\n <mailto:unsubscribe@example.com>\r\n", nil},
+		{"keeps long words whole", "X-Token", strings.Repeat("b", 100), "X-Token: " + strings.Repeat("b", 100) + "\r\n", nil},
+		{"keeps repeated spaces", "Subject", "a  b", "Subject: a  b\r\n", nil},
+		{"rejects CRLF in value", "Subject", "Hello\r\nBcc: victim@example.com", "", ERR_INVALID_HEADER_VALUE},
+		{"rejects LF in value", "Subject", "Hello\nBcc: victim@example.com", "", ERR_INVALID_HEADER_VALUE},
+		{"rejects CR in value", "Subject", "Hello\r", "", ERR_INVALID_HEADER_VALUE},
+		{"rejects line break in name", "X-Bad\r\nBcc", "victim@example.com", "", ERR_INVALID_HEADER_NAME},
+		{"rejects colon in name", "X-Bad:", "value", "", ERR_INVALID_HEADER_NAME},
+		{"rejects empty name", "", "value", "", ERR_INVALID_HEADER_NAME},
+		{"rejects unfoldable lines", "X-Token", strings.Repeat("c", 1000), "", ERR_HEADER_TOO_LONG},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := writeHeader(&buf, test.key, test.value)
+			if err != test.err {
+				t.Fatalf("got error %v, want %v", err, test.err)
+			}
+			if err == nil && buf.String() != test.want {
+				t.Errorf("got %q, want %q", buf.String(), test.want)
+			}
+			for _, line := range strings.Split(buf.String(), "\r\n") {
+				if len(line) > maxLineLength {
+					t.Errorf("line of %v bytes is over the limit", len(line))
+				}
+			}
+		})
+	}
+}
+
+func TestBytesRejectsHeaderInjection(t *testing.T) {
+	msg := &Message{
+		To:      "reader@example.com\r\nBcc: victim@example.com",
+		From:    "list@example.com",
+		Subject: "Hello",
+		Html:    "<p>Hello</p>",
+	}
+	_, err := msg.Bytes()
+	if err != ERR_INVALID_HEADER_VALUE {
+		t.Errorf("got error %v, want %v", err, ERR_INVALID_HEADER_VALUE)
+	}
+}
@@ -1,25 +1,97 @@
 package emailsender
 
 import (
+	"fmt"
 	"html/template"
+	"mime"
 	"strings"
 
 	"github.com/apex/log"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/sesv2"
-	naws "gjhr.me/newsletter/providers/aws"
+	"gjhr.me/newsletter/emailrenderer"
+	"gjhr.me/newsletter/providers/aws"
+	"gjhr.me/newsletter/providers/config"
 )
 
-var ses *sesv2.SESV2
+// Message is a fully rendered email ready to be handed to a Transport.
+type Message struct {
+	To      string
+	From    string
+	ReplyTo string
+	Subject string
+	Html    string
+	Text    string
+	Headers []Header
+}
+
+// Header is an additional header written into the message, folded when long.
+// Values must not contain line breaks.
+type Header struct {
+	Key   string
+	Value string
+}
+
+// ListHeaders returns the RFC 2919 List-Id and RFC 8058 one-click
+// List-Unsubscribe headers for mail sent to a list. Empty values are skipped.
+func ListHeaders(listID string, unsubscribeLink string) []Header {
+	headers := []Header{}
+	if listID != "" {
+		headers = append(headers, Header{"List-Id", listID})
+	}
+	if unsubscribeLink != "" {
+		headers = append(headers,
+			Header{"List-Unsubscribe", fmt.Sprintf("<%v>", unsubscribeLink)},
+			Header{"List-Unsubscribe-Post", "List-Unsubscribe=One-Click"},
+		)
+	}
+	return headers
+}
+
+// Headers identifying the list and issue of a mail in delivery notifications.
+const (
+	HEADER_LIST  = "X-Newsletter-List"
+	HEADER_ISSUE = "X-Newsletter-Issue"
+)
+
+// TrackingHeaders returns headers which tie delivery notifications for a mail
+// back to its list and issue. Empty values are skipped.
+func TrackingHeaders(list string, issue string) []Header {
+	headers := []Header{}
+	if list != "" {
+		headers = append(headers, Header{HEADER_LIST, mime.QEncoding.Encode("utf-8", list)})
+	}
+	if issue != "" {
+		headers = append(headers, Header{HEADER_ISSUE, mime.QEncoding.Encode("utf-8", issue)})
+	}
+	return headers
+}
+
+// Transport delivers rendered messages.
+type Transport interface {
+	Send(msg *Message) error
+}
+
+var transport Transport
 
 func init() {
-	ses = naws.SES()
+	conf := config.Get()
+	switch conf.MailTransport {
+	case "ses":
+		transport = NewSESTransport(aws.SES(), conf.SesConfigurationSet)
+	case "smtp":
+		transport = NewSMTPTransport(conf.SmtpAddress, conf.SmtpUsername, conf.SmtpPassword)
+	case "spool":
+		transport = NewSpoolTransport(conf.SpoolDirectory)
+	default:
+		panic(fmt.Sprintf("Unknown mail transport '%v'", conf.MailTransport))
+	}
+}
+
+// SetTransport replaces the transport used to deliver mail.
+func SetTransport(t Transport) {
+	transport = t
 }
 
-func SendMail(email string, sender string, replyTo string, subject string, template *template.Template, data interface{}) error {
-	//todo add List-Unsubscribe header using raw email (enmime package?)
-	//todo Get email sender name to match newsletter title
-	//todo integration test with inbucket
+func SendMail(email string, sender string, replyTo string, subject string, template *template.Template, data interface{}, headers ...Header) error {
 	log.Infof("Sending email with subject '%v' to '%v'...", subject, email)
 	// Format the body
 	sb := &strings.Builder{}
@@ -28,30 +100,19 @@ func SendMail(email string, sender string, replyTo string, subject string, templ
 		return err
 	}
 
-	_, err = ses.SendEmail(&sesv2.SendEmailInput{
-		Content: &sesv2.EmailContent{
-			Simple: &sesv2.Message{
-				Body: &sesv2.Body{
-					Html: &sesv2.Content{
-						Charset: aws.String("UTF-8"),
-						Data:    aws.String(sb.String()),
-					},
-				},
-				Subject: &sesv2.Content{
-					Charset: aws.String("UTF-8"),
-					Data:    &subject,
-				},
-			},
-		},
-		FromEmailAddress: &sender,
-		ReplyToAddresses: aws.StringSlice([]string{replyTo}),
-		Destination: &sesv2.Destination{
-			ToAddresses: aws.StringSlice([]string{email}),
-		},
-	})
+	// Derive the plain text alternative from the rendered HTML
+	renderer, err := emailrenderer.NewRenderer(strings.NewReader(sb.String()))
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return transport.Send(&Message{
+		To:      email,
+		From:    sender,
+		ReplyTo: replyTo,
+		Subject: subject,
+		Html:    sb.String(),
+		Text:    renderer.Text(),
+		Headers: headers,
+	})
 }
@@ -0,0 +1,41 @@
+package emailsender
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sesv2"
+)
+
+// SESTransport sends mail through the SES v2 API. Messages are sent raw so
+// that list headers survive. Mail is sent with the configuration set, if any,
+// so its events are published.
+type SESTransport struct {
+	client           *sesv2.SESV2
+	configurationSet string
+}
+
+func NewSESTransport(client *sesv2.SESV2, configurationSet string) *SESTransport {
+	return &SESTransport{client: client, configurationSet: configurationSet}
+}
+
+func (t *SESTransport) Send(msg *Message) error {
+	raw, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+	input := &sesv2.SendEmailInput{
+		Content: &sesv2.EmailContent{
+			Raw: &sesv2.RawMessage{
+				Data: raw,
+			},
+		},
+		FromEmailAddress: aws.String(msg.From),
+		Destination: &sesv2.Destination{
+			ToAddresses: aws.StringSlice([]string{msg.To}),
+		},
+	}
+	if t.configurationSet != "" {
+		input.ConfigurationSetName = aws.String(t.configurationSet)
+	}
+	_, err = t.client.SendEmail(input)
+	return err
+}
@@ -0,0 +1,41 @@
+package emailsender
+
+import (
+	"net"
+	"net/mail"
+	"net/smtp"
+)
+
+// SMTPTransport sends mail through an SMTP relay such as a local MailHog or
+// Inbucket instance.
+type SMTPTransport struct {
+	address string
+	auth    smtp.Auth
+}
+
+// NewSMTPTransport creates a transport for the relay at address (host:port).
+// PLAIN authentication is used when a username is given.
+func NewSMTPTransport(address, username, password string) *SMTPTransport {
+	t := &SMTPTransport{address: address}
+	if username != "" {
+		host, _, _ := net.SplitHostPort(address)
+		t.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return t
+}
+
+func (t *SMTPTransport) Send(msg *Message) error {
+	from, err := mail.ParseAddress(msg.From)
+	if err != nil {
+		return err
+	}
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return err
+	}
+	body, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(t.address, t.auth, from.Address, []string{to.Address}, body)
+}
@@ -0,0 +1,33 @@
+package emailsender
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SpoolTransport writes every message to an .eml file in a directory instead
+// of delivering it.
+type SpoolTransport struct {
+	directory string
+}
+
+func NewSpoolTransport(directory string) *SpoolTransport {
+	return &SpoolTransport{directory: directory}
+}
+
+func (t *SpoolTransport) Send(msg *Message) error {
+	body, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(t.directory, 0755)
+	if err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%v-%v.eml", time.Now().UTC().Format("20060102T150405"), uuid.NewString())
+	return os.WriteFile(filepath.Join(t.directory, name), body, 0644)
+}
@@ -0,0 +1,402 @@
+package feedreader
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/mmcdole/gofeed"
+	"gjhr.me/newsletter/data/list"
+	"gjhr.me/newsletter/data/mail"
+	"gjhr.me/newsletter/data/pending"
+	"gjhr.me/newsletter/data/subscription"
+	"gjhr.me/newsletter/data/suppression"
+	"gjhr.me/newsletter/issue"
+	"gjhr.me/newsletter/itemfilter"
+	"gjhr.me/newsletter/mailqueue"
+	"gjhr.me/newsletter/providers/aws"
+	"gjhr.me/newsletter/providers/config"
+	"gjhr.me/newsletter/providers/storage"
+	"gjhr.me/newsletter/tracking"
+	"golang.org/x/exp/slices"
+)
+
+var queue mailqueue.Queue
+
+func init() {
+	queue = mailqueue.NewSQSQueue(aws.SQS(), config.Get().SenderQueueUrl)
+}
+
+// SetQueue replaces the queue new mail is sent to.
+func SetQueue(q mailqueue.Queue) {
+	queue = q
+}
+
+func Handle(ctx context.Context, event events.CloudWatchEvent) error {
+	reqJson, _ := json.Marshal(event)
+	log.Debug(string(reqJson))
+
+	lists, err := storage.Lists().GetAll()
+	if err != nil {
+		return err
+	}
+
+	// Suppressions apply across lists, so are checked against one load a run
+	suppressed, err := suppression.LoadSet(storage.Suppressions())
+	if err != nil {
+		log.WithError(err).Error("Failed to get suppression list")
+		return err
+	}
+
+	hasErrored := false
+
+	now := time.Now()
+
+	for _, l := range *lists {
+		audience, err := LoadAudience(l, suppressed, log.WithField("list", l.Name))
+		if err != nil {
+			hasErrored = true
+			continue
+		}
+		if audience.invalidFilters > 0 {
+			hasErrored = true
+		}
+
+		for fi, feed := range l.Feeds {
+			logger := log.WithFields(log.Fields{
+				"list": l.Name,
+				"feed": feed.Url,
+			})
+			logger.Info("Processing feed")
+			parser := gofeed.NewParser()
+			parsed, err := parser.ParseURL(feed.Url)
+			if err != nil {
+				hasErrored = true
+				logger.WithError(err).Error("Failed to parse feed")
+				continue
+			}
+
+			if parsed.UpdatedParsed != nil && parsed.UpdatedParsed.Equal(feed.LastUpdated) {
+				logger.Info("Not updated since last checked, skipping")
+				continue
+			}
+
+			for _, item := range parsed.Items {
+				// Only process new items published in the last day or later
+				alreadyProcessed := slices.Contains(feed.ProcessedGuids, item.GUID)
+				recentlyPublished := item.PublishedParsed != nil && item.PublishedParsed.After(now.Add(-24*time.Hour))
+				if !alreadyProcessed && recentlyPublished {
+					// Mark guid as processed first to avoid bugs causing multiple sends.
+					err = storage.Lists().UpdateProcessedGuids(l, fi, item.GUID)
+					// If GUID cannot be marked as processed, error out immediately.
+					if err != nil {
+						logger.WithField("guid", item.GUID).WithError(err).Error("Failed to add guid to processed list for feed")
+						return err
+					}
+
+					// Queue up a message here
+					err := QueueMails(item, l, audience, feed.Url, logger)
+					if err != nil {
+						hasErrored = true
+						continue
+					}
+				}
+			}
+
+			// Update last updated
+			err = storage.Lists().UpdateFeedLastUpdated(l, fi)
+			if err != nil {
+				hasErrored = true
+				continue
+			}
+		}
+
+		for _, delivery := range list.DigestDeliveries {
+			err = SendDigest(l, audience, delivery, now, log.WithField("list", l.Name))
+			if err != nil {
+				hasErrored = true
+			}
+		}
+	}
+
+	if hasErrored {
+		return fmt.Errorf("Failed while processing one or more feeds.")
+	}
+
+	return nil
+}
+
+// QueueMails sends a new item to the subscribers of a list who want items
+// immediately and holds it for the digests of the others.
+func QueueMails(item *gofeed.Item, l *list.List, audience *Audience, feedURL string, logger *log.Entry) error {
+	logger = logger.WithFields(log.Fields{
+		"item": item.GUID,
+	})
+	logger.Info("Found new item")
+	if !audience.filter.Allows(item) {
+		logger.Info("Item excluded by the list's filter, skipping")
+		return nil
+	}
+	subs := audience.recipients(l, item, feedURL)
+	var err error
+	for _, delivery := range list.DigestDeliveries {
+		if len(subs[delivery]) == 0 {
+			continue
+		}
+		err = holdForDigest(item, l, delivery, feedURL, logger)
+		if err != nil {
+			return err
+		}
+	}
+	if len(subs[list.DELIVERY_IMMEDIATE]) == 0 {
+		return nil
+	}
+
+	logger.Info("Queueing mail")
+	// Lay the item out as an issue of the list
+	body, links, err := issue.Render(l, item, feedURL)
+	if err != nil {
+		logger.WithError(err).Error("Failed to render issue")
+		return err
+	}
+	return queueIssue(l, issue.ID(l, item), item.Title, body, links, subs[list.DELIVERY_IMMEDIATE], logger)
+}
+
+// SendDigest queues the daily or weekly digest of a list's pending items when
+// one is due.
+func SendDigest(l *list.List, audience *Audience, delivery string, now time.Time, logger *log.Entry) error {
+	logger = logger.WithField("delivery", delivery)
+	if l.LastDigest(delivery).IsZero() {
+		// Start the schedule now rather than sending a digest straight away
+		logger.Info("Starting digest schedule")
+		return storage.Lists().UpdateLastDigest(l, delivery, now)
+	}
+	due, err := l.DigestDue(delivery, now)
+	if err != nil {
+		logger.WithError(err).Error("Failed to check digest schedule")
+		return err
+	}
+	if !due {
+		return nil
+	}
+	scheduled, err := l.LastScheduledDigest(delivery, now)
+	if err != nil {
+		return err
+	}
+	items, err := storage.Pending().GetForList(l.Name, delivery)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get pending items")
+		return err
+	}
+	if len(items) == 0 {
+		logger.Info("No new items for digest")
+		return storage.Lists().UpdateLastDigest(l, delivery, now)
+	}
+	subs := audience.recipients(l, nil, "")
+	feedItems := make([]*gofeed.Item, len(items))
+	for i, item := range items {
+		feedItems[i], err = item.FeedItem()
+		if err != nil {
+			logger.WithField("item", item.GUID).WithError(err).Error("Failed to decode pending item")
+			return err
+		}
+	}
+
+	logger = logger.WithField("digest", issue.DigestID(l, delivery, scheduled))
+	logger.Infof("Digest due, queueing mail for %v items", len(items))
+	// Subscribers who want the same items are sent the same digest
+	editions := map[string]*digestEdition{}
+	for _, sub := range subs[delivery] {
+		wanted := []*pending.PendingItem{}
+		keys := []string{}
+		for i, item := range items {
+			if sub.wants(l, feedItems[i], item.FeedURL) {
+				wanted = append(wanted, item)
+				keys = append(keys, item.Key)
+			}
+		}
+		if len(wanted) == 0 {
+			continue
+		}
+		key := strings.Join(keys, "\n")
+		if editions[key] == nil {
+			editions[key] = &digestEdition{items: wanted}
+		}
+		editions[key].subs = append(editions[key].subs, sub)
+	}
+	for _, edition := range editions {
+		edition.body, edition.links, err = issue.RenderDigest(l, delivery, edition.items, now)
+		if err != nil {
+			logger.WithError(err).Error("Failed to render digest")
+			return err
+		}
+	}
+
+	// Mark the digest sent first to avoid bugs causing multiple sends.
+	err = storage.Lists().UpdateLastDigest(l, delivery, now)
+	if err != nil {
+		logger.WithError(err).Error("Failed to update last digest")
+		return err
+	}
+	for _, item := range items {
+		err = storage.Pending().Delete(item.List, item.Key)
+		if err != nil {
+			logger.WithField("item", item.GUID).WithError(err).Error("Failed to remove pending item")
+			return err
+		}
+	}
+	for _, edition := range editions {
+		err = queueIssue(l, issue.DigestID(l, delivery, scheduled), issue.DigestSubject(l, delivery, now), edition.body, edition.links, edition.subs, logger)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// digestEdition is a digest of the items some subscribers want.
+type digestEdition struct {
+	items []*pending.PendingItem
+	subs  []*subscriber
+	body  string
+	links []string
+}
+
+// holdForDigest keeps a new item until the next daily or weekly digest of the
+// list.
+func holdForDigest(item *gofeed.Item, l *list.List, delivery string, feedURL string, logger *log.Entry) error {
+	logger = logger.WithField("delivery", delivery)
+	logger.Info("Holding item for digest")
+	p, err := pending.New(l.Name, delivery, feedURL, item, time.Now())
+	if err == nil {
+		err = storage.Pending().Put(p)
+	}
+	if err != nil {
+		logger.WithError(err).Error("Failed to hold item for digest")
+	}
+	return err
+}
+
+// Audience is the subscribers of a list who can be sent mail, loaded once a
+// run with the filters of the list and of each subscriber compiled.
+type Audience struct {
+	filter      *itemfilter.Matcher
+	subscribers []*subscriber
+	// Subscribers left out because their stored filter is invalid
+	invalidFilters int
+}
+
+type subscriber struct {
+	*subscription.Subscription
+	filter *itemfilter.Matcher
+}
+
+// LoadAudience gets the subscribers of a list who can be sent mail, leaving
+// out suppressed addresses. A list with an invalid filter is an error, while
+// subscribers with invalid filters are reported and left out.
+func LoadAudience(l *list.List, suppressed suppression.Set, logger *log.Entry) (*Audience, error) {
+	filter, err := l.Filter.Compile()
+	if err != nil {
+		logger.WithError(err).Error("List has an invalid filter, skipping")
+		return nil, err
+	}
+	audience := &Audience{filter: filter}
+
+	// Retrieve list of subscribers
+	logger.Info("Getting all subscribers for list.")
+	subs, err := storage.Subscriptions().GetAllVerifiedFromList(l.Name)
+	if err != nil {
+		logger.WithError(err).Error("Failed to get subscribers for list")
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, sub := range *subs {
+		subLogger := logger.WithField("subscription", sub.Email)
+		if sub.Suspended {
+			subLogger.Info("Subscription suspended after soft bounces, skipping")
+			continue
+		}
+		if sub.IsPaused(now) {
+			subLogger.Info("Subscription paused, skipping")
+			continue
+		}
+		if suppressed.Contains(sub.Email) {
+			subLogger.Info("Address suppressed, skipping")
+			continue
+		}
+		filter, err := sub.Filter.Compile()
+		if err != nil {
+			subLogger.WithError(err).Error("Subscription has an invalid filter, skipping")
+			audience.invalidFilters++
+			continue
+		}
+		audience.subscribers = append(audience.subscribers, &subscriber{Subscription: sub, filter: filter})
+	}
+	return audience, nil
+}
+
+// recipients returns the audience by how they want new items sent. Given an
+// item, only subscribers who want it are returned.
+func (a *Audience) recipients(l *list.List, item *gofeed.Item, feedURL string) map[string][]*subscriber {
+	byDelivery := map[string][]*subscriber{}
+	for _, sub := range a.subscribers {
+		if item != nil && !sub.wants(l, item, feedURL) {
+			continue
+		}
+		delivery := l.DeliveryFor(sub.Subscription)
+		byDelivery[delivery] = append(byDelivery[delivery], sub)
+	}
+	return byDelivery
+}
+
+// wants reports whether a subscriber wants an item of one of the list's feeds,
+// by the feeds they chose and their own filter.
+func (sub *subscriber) wants(l *list.List, item *gofeed.Item, feedURL string) bool {
+	return sub.WantsFeed(l.FeedKey(feedURL)) && sub.filter.Allows(item)
+}
+
+// queueIssue saves a rendered issue and queues a mail of it to each of the
+// subscribers.
+func queueIssue(l *list.List, issueID string, subject string, body string, links []string, subs []*subscriber, logger *log.Entry) error {
+	// Save body to template storage
+	// Get hash of content
+	logger.Info("Saving content to template storage")
+	hasher := sha1.New()
+	hasher.Write([]byte(body))
+	sha := base64.URLEncoding.EncodeToString(hasher.Sum(nil))
+
+	err := storage.Templates().Put(sha, body)
+	if err != nil {
+		logger.WithError(err).Error("Failed to save item to template storage")
+		return err
+	}
+
+	// For each subscriber queue a mail
+	for _, sub := range subs {
+		subLogger := logger.WithField("subscription", sub.Email)
+		subLogger.Info("Queuing email")
+
+		msg := mail.New(sub.Subscription, l, subject, config.Get().TemplateBucket, sha)
+		msg.Issue = issueID
+		if len(links) > 0 {
+			msg.TemplateValues.ClickLinks = tracking.ClickLinks(l, msg.Issue, links, *sub.Subscription)
+		}
+		if l.TrackOpens {
+			msg.TemplateValues.OpenPixel = tracking.OpenPixel(l, msg.Issue, *sub.Subscription, time.Now())
+		}
+		err = queue.Enqueue(msg, issueID, mailqueue.DeduplicationID(issueID, sub.Email))
+		if err != nil {
+			subLogger.WithError(err).Error("Failed to queue email")
+			//todo return err here?
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package feedreader
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	"gjhr.me/newsletter/data/list"
+	"gjhr.me/newsletter/data/subscription"
+	"gjhr.me/newsletter/data/suppression"
+	"gjhr.me/newsletter/itemfilter"
+	"gjhr.me/newsletter/providers/storage"
+)
+
+func setUp(t *testing.T, l *list.List, subs ...*subscription.Subscription) {
+	t.Helper()
+	err := storage.Use("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.Lists().Put(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sub := range subs {
+		err = storage.Subscriptions().Put(sub)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadAudienceRejectsInvalidListFilter(t *testing.T) {
+	l := &list.List{Name: "list", Filter: &itemfilter.Filter{Exclude: []itemfilter.Rule{{Title: "("}}}}
+	setUp(t, l)
+
+	_, err := LoadAudience(l, suppression.Set{}, log.WithField("list", l.Name))
+	if err != itemfilter.ERR_INVALID_TITLE_PATTERN {
+		t.Errorf("got error %v, want %v", err, itemfilter.ERR_INVALID_TITLE_PATTERN)
+	}
+}
+
+func TestLoadAudienceReportsInvalidSubscriberFilters(t *testing.T) {
+	l := &list.List{Name: "list"}
+	setUp(t, l,
+		&subscription.Subscription{Email: "valid@example.com", List: l.Name, Verified: "yes",
+			Filter: &itemfilter.Filter{Exclude: []itemfilter.Rule{{Title: "^Sponsored"}}}},
+		&subscription.Subscription{Email: "invalid@example.com", List: l.Name, Verified: "yes",
+			Filter: &itemfilter.Filter{Exclude: []itemfilter.Rule{{Title: "["}}}},
+		&subscription.Subscription{Email: "empty@example.com", List: l.Name, Verified: "yes",
+			Filter: &itemfilter.Filter{Include: []itemfilter.Rule{{}}}},
+	)
+
+	audience, err := LoadAudience(l, suppression.Set{}, log.WithField("list", l.Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if audience.invalidFilters != 2 {
+		t.Errorf("counted %v invalid filters, want 2", audience.invalidFilters)
+	}
+	if len(audience.subscribers) != 1 || audience.subscribers[0].Email != "valid@example.com" {
+		t.Errorf("got subscribers %v, want only valid@example.com", audience.subscribers)
+	}
+}
+
+func TestLoadAudienceLeavesOutSuppressedAddresses(t *testing.T) {
+	l := &list.List{Name: "list"}
+	setUp(t, l,
+		&subscription.Subscription{Email: "reader@example.com", List: l.Name, Verified: "yes"},
+		&subscription.Subscription{Email: "Bounced@Example.com", List: l.Name, Verified: "yes"},
+	)
+	err := storage.Suppressions().Put(suppression.New("bounced@example.com", suppression.REASON_BOUNCE, "", time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	suppressed, err := suppression.LoadSet(storage.Suppressions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	audience, err := LoadAudience(l, suppressed, log.WithField("list", l.Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(audience.subscribers) != 1 || audience.subscribers[0].Email != "reader@example.com" {
+		t.Errorf("got subscribers %v, want only reader@example.com", audience.subscribers)
+	}
+}
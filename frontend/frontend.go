@@ -0,0 +1,342 @@
+package frontend
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"github.com/aquasecurity/lmdrouter"
+	"github.com/aws/aws-lambda-go/events"
+	"gjhr.me/newsletter/bouncehandler"
+	"gjhr.me/newsletter/data/list"
+	"gjhr.me/newsletter/data/subscription"
+	"gjhr.me/newsletter/listtemplates"
+	"gjhr.me/newsletter/providers/config"
+	"gjhr.me/newsletter/providers/storage"
+	"gjhr.me/newsletter/subscriptionflow"
+	"gjhr.me/newsletter/tracking"
+	"gjhr.me/newsletter/utils/loggermiddleware"
+	"gjhr.me/newsletter/utils/snsmessage"
+	"golang.org/x/exp/slices"
+)
+
+var router *lmdrouter.Router
+
+type htmlContent struct {
+	Title        string
+	List         *list.List
+	Subscription *subscription.Subscription
+	Err          error
+	// Token of the link the page was reached by, for forms posting back
+	Token string
+	// Outcome of a form submission
+	Message string
+	// Keys of the feeds chosen by a link, empty for all of them
+	Feeds []string
+}
+
+// FeedChosen reports whether a feed is chosen by the link the page was
+// reached by.
+func (c htmlContent) FeedChosen(key string) bool {
+	return len(c.Feeds) == 0 || slices.Contains(c.Feeds, key)
+}
+
+func init() {
+	router = lmdrouter.NewRouter("", loggermiddleware.LoggerMiddleware)
+
+	// GETs because these are primarily interacted through with links
+	router.Route("GET", "/", root)
+	router.Route("GET", "/subscribe", subscribe)
+	router.Route("GET", "/verify", verify)
+	router.Route("GET", "/unsubscribe", unsubscribe)
+	// RFC 8058 one-click unsubscribe, posted by mail clients
+	router.Route("POST", "/unsubscribe", oneClickUnsubscribe)
+	router.Route("GET", "/r/:token", click)
+	router.Route("GET", "/o/:token", open)
+	router.Route("POST", "/sns", sns)
+	router.Route("GET", "/preferences", preferences)
+	router.Route("POST", "/preferences", updatePreferences)
+}
+
+func Router() *lmdrouter.Router {
+	return router
+}
+
+func root(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	list, err := storage.Lists().GetFromDomain(req.RequestContext.DomainName)
+	if err != nil {
+		return returnErr(err)
+	}
+	// Links to the index can choose feeds for the subscriber
+	return returnHtml(200, listtemplates.INDEX, htmlContent{Title: list.Name, List: list, Feeds: req.MultiValueQueryStringParameters["feed"]})
+}
+
+func subscribe(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	list, err := storage.Lists().GetFromDomain(req.RequestContext.DomainName)
+	if err != nil {
+		return returnErr(err)
+	}
+	subscription, err := listmanagement.Subscribe(list, req.QueryStringParameters["email"], req.QueryStringParameters["delivery"], req.MultiValueQueryStringParameters["feed"])
+	if err != nil {
+		return returnErr(err)
+	}
+	return returnHtml(200, listtemplates.SUBSCRIBE, htmlContent{Title: "Verification Needed", List: list, Subscription: subscription})
+}
+
+func unsubscribe(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	list, err := storage.Lists().GetFromDomain(req.RequestContext.DomainName)
+	if err != nil {
+		return returnErr(err)
+	}
+	token := req.QueryStringParameters["token"]
+	if token == "" {
+		// No token means the address was entered on the index page, mail a link
+		// rather than trusting it.
+		err = listmanagement.RequestUnsubscribe(list, req.QueryStringParameters["email"])
+		if err != nil {
+			return returnErr(err)
+		}
+		return returnHtml(200, listtemplates.UNSUBSCRIBE_REQUESTED, htmlContent{Title: "Check Your Email", List: list})
+	}
+	err = listmanagement.Unsubscribe(list, token)
+	if err != nil {
+		return returnErr(err)
+	}
+	return returnHtml(200, listtemplates.UNSUBSCRIBE, htmlContent{Title: "Unsubscribed", List: list})
+}
+
+func oneClickUnsubscribe(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	list, err := storage.Lists().GetFromDomain(req.RequestContext.DomainName)
+	if err != nil {
+		return returnText(404, err.Error())
+	}
+	body, err := requestBody(req)
+	if err != nil {
+		return returnText(400, "Malformed body")
+	}
+	form, err := url.ParseQuery(body)
+	if err != nil || form.Get("List-Unsubscribe") != "One-Click" {
+		return returnText(400, "Expected List-Unsubscribe=One-Click")
+	}
+	err = listmanagement.Unsubscribe(list, req.QueryStringParameters["token"])
+	if err != nil {
+		return returnText(400, err.Error())
+	}
+	return returnText(200, "Unsubscribed")
+}
+
+func verify(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	list, err := storage.Lists().GetFromDomain(req.RequestContext.DomainName)
+	if err != nil {
+		return returnErr(err)
+	}
+	err = listmanagement.Verify(req.QueryStringParameters["token"])
+	if err != nil {
+		return returnErr(err)
+	}
+	return returnHtml(200, listtemplates.VERIFY, htmlContent{Title: "Welcome", List: list})
+}
+
+// click records a click on a tracked link and redirects to its destination.
+func click(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	c, err := tracking.ParseClick(req.PathParameters["token"])
+	if err != nil {
+		return returnText(404, "Link not found")
+	}
+	// A failure to count the click should not keep the reader from the link
+	err = storage.Clicks().Record(c.Issue, c.Link, c.Subscriber, time.Now())
+	if err != nil {
+		log.WithError(err).WithField("issue", c.Issue).Error("Failed to record click")
+	}
+	return events.APIGatewayProxyResponse{
+		StatusCode: 302,
+		Headers: map[string]string{
+			"Location":      c.Link,
+			"Cache-Control": "no-store",
+		},
+	}, nil
+}
+
+// Transparent 1x1 GIF
+const pixel = "R0lGODlhAQABAIAAAAAAAP///yH5BAEAAAAALAAAAAABAAEAAAIBRAA7"
+
+// open records an open of an issue through its tracking pixel. The pixel is
+// served whatever happens so mail clients never show a broken image.
+func open(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	o, err := tracking.ParseOpen(req.PathParameters["token"])
+	if err == nil {
+		now := time.Now()
+		machine := tracking.IsMachineOpen(req.RequestContext.Identity.UserAgent, tracking.SentAt(storage.Opens(), o), now)
+		err = storage.Opens().Record(o.Issue, o.Subscriber, now, machine)
+		if err != nil {
+			log.WithError(err).WithField("issue", o.Issue).Error("Failed to record open")
+		}
+	}
+	return events.APIGatewayProxyResponse{
+		StatusCode: 200,
+		Headers: map[string]string{
+			"Content-Type":  "image/gif",
+			"Cache-Control": "no-store",
+		},
+		Body:            pixel,
+		IsBase64Encoded: true,
+	}, nil
+}
+
+// sns receives SES notifications from an SNS HTTPS subscription. It is only
+// enabled when the topics to accept are configured.
+func sns(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if config.Get().SnsTopicArns == "" {
+		return returnText(403, "SNS notifications are not enabled")
+	}
+	body, err := requestBody(req)
+	if err != nil {
+		return returnText(400, "Malformed body")
+	}
+	var m snsmessage.Message
+	err = json.Unmarshal([]byte(body), &m)
+	if err != nil {
+		return returnText(400, "Malformed SNS message")
+	}
+	err = bouncehandler.HandleSNS(&m)
+	switch err {
+	case nil:
+		return returnText(200, "OK")
+	case bouncehandler.ERR_UNEXPECTED_TOPIC, snsmessage.ERR_UNSUPPORTED_SIGNATURE_VERSION,
+		snsmessage.ERR_UNTRUSTED_URL, snsmessage.ERR_INVALID_CERTIFICATE, snsmessage.ERR_INVALID_SIGNATURE:
+		return returnText(403, err.Error())
+	}
+	// SNS retries deliveries that fail
+	log.WithError(err).Error("Failed to handle SNS message")
+	return returnText(500, "Failed to handle SNS message")
+}
+
+// preferences shows a subscriber their subscription and lets them change it.
+func preferences(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	list, err := storage.Lists().GetFromDomain(req.RequestContext.DomainName)
+	if err != nil {
+		return returnErr(err)
+	}
+	token := req.QueryStringParameters["token"]
+	sub, err := listmanagement.Preferences(list, token)
+	if err != nil {
+		return returnErr(err)
+	}
+	return returnHtml(200, listtemplates.PREFERENCES, htmlContent{Title: "Preferences", List: list, Subscription: sub, Token: token})
+}
+
+func updatePreferences(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	list, err := storage.Lists().GetFromDomain(req.RequestContext.DomainName)
+	if err != nil {
+		return returnErr(err)
+	}
+	token := req.QueryStringParameters["token"]
+	sub, err := listmanagement.Preferences(list, token)
+	if err != nil {
+		return returnErr(err)
+	}
+	body, err := requestBody(req)
+	if err != nil {
+		return returnText(400, "Malformed body")
+	}
+	form, err := url.ParseQuery(body)
+	if err != nil {
+		return returnText(400, "Malformed form")
+	}
+
+	content := htmlContent{Title: "Preferences", List: list, Subscription: sub, Token: token}
+	// An empty delivery follows the list's, including when the list changes it
+	sub.Delivery = form.Get("delivery")
+	sub.Feeds = form["feed"]
+	// The feeds are only offered when there is a choice, an empty choice is
+	// not all of them
+	if len(list.Feeds) > 1 && len(sub.Feeds) == 0 {
+		content.Err = listmanagement.ERR_NO_FEEDS_CHOSEN
+		return returnHtml(400, listtemplates.PREFERENCES, content)
+	}
+	sub.PausedUntil = time.Time{}
+	if pausedUntil := form.Get("paused_until"); pausedUntil != "" {
+		sub.PausedUntil, err = time.Parse(pauseDateFormat, pausedUntil)
+		if err != nil {
+			content.Err = err
+			return returnHtml(400, listtemplates.PREFERENCES, content)
+		}
+	}
+	err = listmanagement.UpdatePreferences(list, sub)
+	if err != nil {
+		content.Err = err
+		return returnHtml(400, listtemplates.PREFERENCES, content)
+	}
+	content.Message = "Your preferences have been saved."
+
+	if email := form.Get("email"); email != "" && email != sub.Email {
+		moved, err := listmanagement.ChangeEmail(list, sub, email)
+		if err != nil {
+			content.Err = err
+			return returnHtml(400, listtemplates.PREFERENCES, content)
+		}
+		content.Message = fmt.Sprintf("Your preferences have been saved. Follow the link sent to '%v' to move your subscription to it.", moved.Email)
+	}
+	return returnHtml(200, listtemplates.PREFERENCES, content)
+}
+
+// Format of dates in the preferences form.
+const pauseDateFormat = "2006-01-02"
+
+// requestBody returns the body of a request, decoding it if it is binary.
+func requestBody(req events.APIGatewayProxyRequest) (string, error) {
+	if !req.IsBase64Encoded {
+		return req.Body, nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(req.Body)
+	return string(decoded), err
+}
+
+// todo make errors HTTPErrors and handle automatically
+func returnErr(err error) (events.APIGatewayProxyResponse, error) {
+	log.Errorf("Unexpected uncaught error: %v", err)
+	return returnHtml(500, listtemplates.ERROR, htmlContent{
+		Title: "Error",
+		Err:   err,
+	})
+}
+
+func returnText(status int, body string) (events.APIGatewayProxyResponse, error) {
+	return events.APIGatewayProxyResponse{
+		StatusCode: status,
+		Headers: map[string]string{
+			"Content-Type": "text/plain",
+		},
+		Body: body,
+	}, nil
+}
+
+func returnHtml(status int, templateName string, content htmlContent) (events.APIGatewayProxyResponse, error) {
+	templates, err := listtemplates.Load(content.List)
+	if err != nil {
+		log.WithError(err).Error("Failed to load list templates, falling back to defaults")
+		templates, err = listtemplates.Load(nil)
+		if err != nil {
+			return lmdrouter.HandleError(err)
+		}
+	}
+	b := &strings.Builder{}
+	err = templates.ExecuteTemplate(b, templateName, content)
+	if err != nil {
+		return lmdrouter.HandleError(err) // Fallback error handler
+	}
+	response := events.APIGatewayProxyResponse{
+		StatusCode: status,
+		Headers: map[string]string{
+			"Content-Type": "text/html",
+		},
+		Body: b.String(),
+	}
+
+	return response, nil
+}
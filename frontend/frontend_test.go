@@ -0,0 +1,76 @@
+package frontend
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"gjhr.me/newsletter/data/list"
+	"gjhr.me/newsletter/data/subscription"
+	"gjhr.me/newsletter/providers/signing"
+	"gjhr.me/newsletter/providers/storage"
+)
+
+func TestUpdatePreferencesDelivery(t *testing.T) {
+	tests := []struct {
+		name     string
+		stored   string
+		chosen   string
+		want     string
+		selected string
+	}{
+		{"keeps following the list", "", "", "", `<option value="" selected>List default (daily)</option>`},
+		{"chooses the list's mode", "", list.DELIVERY_DAILY, list.DELIVERY_DAILY, `<option value="daily" selected>`},
+		{"chooses another mode", "", list.DELIVERY_WEEKLY, list.DELIVERY_WEEKLY, `<option value="weekly" selected>`},
+		{"returns to the list's", list.DELIVERY_WEEKLY, "", "", `<option value="" selected>List default (daily)</option>`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := signing.UseEphemeralKey()
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = storage.Use("memory", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			l := &list.List{Name: "list", Domain: "list.example.com", FromAddress: "list@example.com", Delivery: list.DELIVERY_DAILY}
+			err = storage.Lists().Put(l)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = storage.Subscriptions().Put(&subscription.Subscription{Email: "reader@example.com", List: l.Name, Verified: "yes", VerificationToken: "token", Delivery: test.stored})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			link, err := url.Parse(l.FormatPreferencesLink(subscription.Subscription{Email: "reader@example.com", VerificationToken: "token"}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := updatePreferences(context.Background(), events.APIGatewayProxyRequest{
+				RequestContext:        events.APIGatewayProxyRequestContext{DomainName: l.Domain},
+				QueryStringParameters: map[string]string{"token": link.Query().Get("token")},
+				Body:                  url.Values{"delivery": {test.chosen}}.Encode(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != 200 {
+				t.Fatalf("got status %v: %v", res.StatusCode, res.Body)
+			}
+			sub, err := storage.Subscriptions().Get(l.Name, "reader@example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sub.Delivery != test.want {
+				t.Errorf("stored delivery %q, want %q", sub.Delivery, test.want)
+			}
+			if !strings.Contains(res.Body, test.selected) {
+				t.Errorf("form does not have %v selected: %v", test.selected, res.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package frontend
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+// HTTPHandler serves the router over plain net/http, translating requests
+// into the API Gateway proxy events the handlers expect.
+func HTTPHandler() http.Handler {
+	return http.HandlerFunc(serveHTTP)
+}
+
+func serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := events.APIGatewayProxyRequest{
+		Path:                            r.URL.Path,
+		HTTPMethod:                      r.Method,
+		Headers:                         singleValues(r.Header),
+		MultiValueHeaders:               r.Header,
+		QueryStringParameters:           singleValues(r.URL.Query()),
+		MultiValueQueryStringParameters: r.URL.Query(),
+		Body:                            string(body),
+		RequestContext: events.APIGatewayProxyRequestContext{
+			RequestID:  uuid.NewString(),
+			DomainName: r.Host,
+			Path:       r.URL.Path,
+			HTTPMethod: r.Method,
+			Identity: events.APIGatewayRequestIdentity{
+				SourceIP:  r.RemoteAddr,
+				UserAgent: r.UserAgent(),
+			},
+		},
+	}
+	// API Gateway always sets the Host header
+	req.Headers["Host"] = r.Host
+
+	res, err := router.Handler(r.Context(), req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for key, values := range res.MultiValueHeaders {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	for key, value := range res.Headers {
+		w.Header().Set(key, value)
+	}
+	body = []byte(res.Body)
+	if res.IsBase64Encoded {
+		body, err = base64.StdEncoding.DecodeString(res.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.WriteHeader(res.StatusCode)
+	w.Write(body)
+}
+
+func singleValues(in map[string][]string) map[string]string {
+	out := map[string]string{}
+	for key, values := range in {
+		if len(values) > 0 {
+			out[key] = values[0]
+		}
+	}
+	return out
+}
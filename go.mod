@@ -2,11 +2,14 @@ module gjhr.me/newsletter
 
 go 1.19
 
-require github.com/aws/aws-sdk-go v1.44.109
+require (
+	github.com/andybalholm/cascadia v1.1.0
+	github.com/aws/aws-sdk-go v1.44.109
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/PuerkitoBio/goquery v1.5.1 // indirect
-	github.com/andybalholm/cascadia v1.1.0 // indirect
 	github.com/blmayer/awslambdarpc v1.0.1 // indirect
 	github.com/cenkalti/backoff/v4 v4.1.2 // indirect
 	github.com/fsnotify/fsnotify v1.6.0 // indirect
@@ -30,7 +33,6 @@ require (
 	golang.org/x/text v0.4.0 // indirect
 	gopkg.in/ini.v1 v1.67.0 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
 
 require (
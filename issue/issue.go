@@ -0,0 +1,261 @@
+package issue
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"gjhr.me/newsletter/data/list"
+	"gjhr.me/newsletter/data/pending"
+	"gjhr.me/newsletter/emailrenderer"
+	"gjhr.me/newsletter/listtemplates"
+	"golang.org/x/exp/slices"
+)
+
+// IDs of the elements filled in by Render.
+const (
+	LIST_NAME_ID        = "issue-list-name"
+	FOOTER_LIST_NAME_ID = "issue-footer-list-name"
+	TITLE_ID            = "issue-title"
+	LINK_ID             = "issue-link"
+	READ_ONLINE_ID      = "issue-read-online"
+	AUTHOR_ID           = "issue-author"
+	DATE_ID             = "issue-date"
+	CONTENT_ID          = listtemplates.ISSUE_CONTENT_ID
+	DIGEST_TITLE_ID     = "digest-title"
+	DIGEST_DATE_ID      = "digest-date"
+)
+
+const dateFormat = "2 January 2006"
+
+// ID identifies the issue of a list sent for a feed item.
+func ID(l *list.List, item *gofeed.Item) string {
+	return l.Name + "/" + item.GUID
+}
+
+// Render lays out a feed item as an issue of a list using the list's issue
+// layout. The result is the template the sender executes for each subscriber.
+// Relative URLs in the item are resolved against its link, or failing that
+// the URL of the feed it came from.
+//
+// When the list tracks clicks, links are rewritten to entries of the mail's
+// ClickLinks and the destinations of those entries are returned. When it
+// tracks opens, the mail's OpenPixel is added to the end of the issue.
+func Render(l *list.List, item *gofeed.Item, feedURL string) (body string, links []string, err error) {
+	r, err := layout(l, listtemplates.ISSUE_LAYOUT)
+	if err != nil {
+		return "", nil, err
+	}
+	err = fillItem(r, item, feedURL, "")
+	if err != nil {
+		return "", nil, err
+	}
+	return finish(l, r)
+}
+
+// RenderDigest lays out pending items as a daily or weekly digest of a list
+// sent at the given time using the list's digest layout, oldest item first.
+// Links are tracked as they are by Render.
+func RenderDigest(l *list.List, delivery string, items []*pending.PendingItem, at time.Time) (body string, links []string, err error) {
+	type entry struct {
+		item    *gofeed.Item
+		feedURL string
+		at      time.Time
+	}
+	entries := make([]entry, len(items))
+	for i, p := range items {
+		item, err := p.FeedItem()
+		if err != nil {
+			return "", nil, err
+		}
+		entries[i] = entry{item: item, feedURL: p.FeedURL, at: p.Queued}
+		if item.PublishedParsed != nil {
+			entries[i].at = *item.PublishedParsed
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].at.Before(entries[j].at) })
+
+	r, err := layout(l, listtemplates.DIGEST_LAYOUT)
+	if err != nil {
+		return "", nil, err
+	}
+	r.ReplaceTextByID(map[string]string{
+		DIGEST_TITLE_ID: digestTitle(delivery),
+		DIGEST_DATE_ID:  digestDate(l, at),
+	})
+	r.RepeatByID(listtemplates.DIGEST_ITEM_ID, len(entries))
+	for i, e := range entries {
+		err = fillItem(r, e.item, e.feedURL, fmt.Sprintf("-%v", i))
+		if err != nil {
+			return "", nil, err
+		}
+	}
+	return finish(l, r)
+}
+
+// DigestID identifies the daily or weekly digest of a list scheduled at the
+// given time.
+func DigestID(l *list.List, delivery string, scheduled time.Time) string {
+	return l.Name + "/" + delivery + "/" + scheduled.UTC().Format("2006-01-02T15:04")
+}
+
+// DigestSubject is the subject of the daily or weekly digest of a list sent at
+// the given time.
+func DigestSubject(l *list.List, delivery string, at time.Time) string {
+	return fmt.Sprintf("%v: %v, %v", l.Name, digestTitle(delivery), digestDate(l, at))
+}
+
+func digestTitle(delivery string) string {
+	if delivery == list.DELIVERY_WEEKLY {
+		return "Weekly digest"
+	}
+	return "Daily digest"
+}
+
+func digestDate(l *list.List, at time.Time) string {
+	if loc, err := time.LoadLocation(l.DigestTimezone); err == nil {
+		at = at.In(loc)
+	}
+	return at.Format(dateFormat)
+}
+
+// layout loads one of the list's layouts and fills in the list's name.
+func layout(l *list.List, name string) (*emailrenderer.Renderer, error) {
+	source, err := listtemplates.Source(l, name)
+	if err != nil {
+		return nil, err
+	}
+	r, err := emailrenderer.NewRenderer(strings.NewReader(source))
+	if err != nil {
+		return nil, err
+	}
+	r.ReplaceTextByID(map[string]string{
+		LIST_NAME_ID:        emailrenderer.EscapeTemplateText(l.Name),
+		FOOTER_LIST_NAME_ID: emailrenderer.EscapeTemplateText(l.Name),
+	})
+	return r, nil
+}
+
+// fillItem fills in the elements of an item, whose IDs end in the suffix.
+func fillItem(r *emailrenderer.Renderer, item *gofeed.Item, feedURL string, suffix string) error {
+	text := map[string]string{
+		TITLE_ID + suffix: emailrenderer.EscapeTemplateText(item.Title),
+	}
+	if author := authorOf(item); author != "" {
+		text[AUTHOR_ID+suffix] = emailrenderer.EscapeTemplateText(author)
+	} else {
+		r.RemoveByID(AUTHOR_ID + suffix)
+	}
+	if item.PublishedParsed != nil {
+		text[DATE_ID+suffix] = item.PublishedParsed.Format(dateFormat)
+	} else {
+		r.RemoveByID(DATE_ID + suffix)
+	}
+	r.ReplaceTextByID(text)
+
+	if item.Link != "" {
+		link := emailrenderer.EscapeTemplateText(item.Link)
+		r.ReplaceHrefByID(map[string]string{
+			LINK_ID + suffix:        link,
+			READ_ONLINE_ID + suffix: link,
+		})
+	} else {
+		r.RemoveByID(READ_ONLINE_ID + suffix)
+	}
+
+	content, err := prepareContent(item, feedURL)
+	if err != nil {
+		return err
+	}
+	return r.ReplaceInnerHTMLByID(map[string]string{CONTENT_ID + suffix: content})
+}
+
+// finish inlines the issue's styles and adds tracking.
+func finish(l *list.List, r *emailrenderer.Renderer) (body string, links []string, err error) {
+	r.InlineCSS()
+	if l.TrackClicks {
+		links = trackLinks(r)
+	}
+	if l.TrackOpens {
+		r.AppendPixel("{{ .OpenPixel }}")
+	}
+	body, err = r.String()
+	return body, links, err
+}
+
+// trackLinks rewrites web links to their index in the mail's tracked links.
+// Links which are already template actions, such as the unsubscribe link, are
+// not tracked.
+func trackLinks(r *emailrenderer.Renderer) []string {
+	links := []string{}
+	r.RewriteLinks(func(href string) (string, bool) {
+		if strings.Contains(href, "{{") {
+			return "", false
+		}
+		parsed, err := url.Parse(href)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return "", false
+		}
+		i := slices.Index(links, href)
+		if i < 0 {
+			i = len(links)
+			links = append(links, href)
+		}
+		return fmt.Sprintf("{{ index .ClickLinks %v }}", i), true
+	})
+	return links
+}
+
+func authorOf(item *gofeed.Item) string {
+	if item.Author != nil {
+		if item.Author.Name != "" {
+			return item.Author.Name
+		}
+		return item.Author.Email
+	}
+	return ""
+}
+
+// prepareContent makes the item's content fit to be placed in an email. This
+// is done on its own so the layout's links, which are templates, are left be.
+func prepareContent(item *gofeed.Item, feedURL string) (string, error) {
+	base, err := url.Parse(feedURL)
+	if err != nil {
+		return "", err
+	}
+	if item.Link != "" {
+		link, err := url.Parse(item.Link)
+		if err == nil {
+			base = base.ResolveReference(link)
+		}
+	}
+
+	r, err := emailrenderer.NewRenderer(strings.NewReader(contentOf(item)))
+	if err != nil {
+		return "", err
+	}
+	// Inline the content's own styles before the sanitizer drops them
+	r.InlineCSS()
+	r.StripUnsafe()
+	r.ResolveURLs(base)
+	r.Sanitize(emailrenderer.ContentPolicy)
+	r.SetImageDefaults(listtemplates.ISSUE_CONTENT_WIDTH)
+	content, err := r.BodyString()
+	if err != nil {
+		return "", err
+	}
+	// The issue is executed as a template by the sender, the content is data
+	return emailrenderer.EscapeTemplateText(content), nil
+}
+
+// contentOf prefers the full content of an item, falling back to its
+// description for feeds which only publish summaries.
+func contentOf(item *gofeed.Item) string {
+	if item.Content != "" {
+		return item.Content
+	}
+	return item.Description
+}
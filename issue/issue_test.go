@@ -0,0 +1,42 @@
+package issue
+
+import (
+	"html/template"
+	"strings"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+)
+
+func TestPrepareContentEscapesTemplateActions(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"text", `<p>a {{ .Email }} b</p>`},
+		{"attribute", `<p><a href="https://example.com/{{ .Email }}">a</a></p>`},
+		{"removed comment", `<p>a {<!-- x -->{ .Email }} b</p>`},
+		{"unwrapped element", `<p>a {<font>{ .Email }}</font> b</p>`},
+		{"removed element", `<p>a {<script>x</script>{ .Email }} b</p>`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			content, err := prepareContent(&gofeed.Item{Content: test.content}, "https://example.com/feed")
+			if err != nil {
+				t.Fatal(err)
+			}
+			tmpl, err := template.New("body").Parse(content)
+			if err != nil {
+				t.Fatalf("content does not parse as a template: %v\n%v", err, content)
+			}
+			var out strings.Builder
+			err = tmpl.Execute(&out, struct{ Email string }{"subscriber@example.com"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(out.String(), "subscriber@example.com") {
+				t.Errorf("template action in content was run: %v", out.String())
+			}
+		})
+	}
+}
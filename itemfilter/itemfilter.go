@@ -0,0 +1,265 @@
+// Package itemfilter decides which feed items are sent, by rules matching
+// their categories, title, authors and content.
+package itemfilter
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+	"gjhr.me/newsletter/utils/consterror"
+	"golang.org/x/net/html"
+)
+
+const (
+	ERR_EMPTY_RULE            = consterror.ConstError("Filter rules must have at least one condition")
+	ERR_INVALID_TITLE_PATTERN = consterror.ConstError("Title pattern is not a valid regular expression")
+)
+
+// Filter decides which items are sent. A nil Filter allows every item.
+type Filter struct {
+	// Items must match one of these rules when there are any
+	Include []Rule `dynamo:"include,omitempty" json:"include,omitempty"`
+	// Items matching any of these rules are not sent
+	Exclude []Rule `dynamo:"exclude,omitempty" json:"exclude,omitempty"`
+}
+
+// Rule matches items meeting all of its conditions. Conditions listing
+// several values are met by any one of them, ignoring case.
+type Rule struct {
+	Categories []string `dynamo:"categories,omitempty" json:"categories,omitempty"`
+	// Regular expression matched against the title
+	Title   string   `dynamo:"title,omitempty" json:"title,omitempty"`
+	Authors []string `dynamo:"authors,omitempty" json:"authors,omitempty"`
+	// Words or phrases found in the title, description or content
+	Keywords []string `dynamo:"keywords,omitempty" json:"keywords,omitempty"`
+}
+
+// IsEmpty reports whether the filter has no rules, so allows every item.
+func (f *Filter) IsEmpty() bool {
+	return f == nil || len(f.Include) == 0 && len(f.Exclude) == 0
+}
+
+// Validate checks every rule has a condition and a valid title pattern.
+func (f *Filter) Validate() error {
+	_, err := f.Compile()
+	return err
+}
+
+// Copy returns a copy of the filter sharing no state with it.
+func (f *Filter) Copy() *Filter {
+	if f == nil {
+		return nil
+	}
+	return &Filter{Include: copyRules(f.Include), Exclude: copyRules(f.Exclude)}
+}
+
+func copyRules(rules []Rule) []Rule {
+	if rules == nil {
+		return nil
+	}
+	copied := make([]Rule, len(rules))
+	for i, rule := range rules {
+		copied[i] = Rule{
+			Categories: append([]string(nil), rule.Categories...),
+			Title:      rule.Title,
+			Authors:    append([]string(nil), rule.Authors...),
+			Keywords:   append([]string(nil), rule.Keywords...),
+		}
+	}
+	return copied
+}
+
+// Validate checks the rule has a condition and a valid title pattern.
+func (r Rule) Validate() error {
+	_, err := r.compile()
+	return err
+}
+
+// Matcher is a compiled Filter, to be compiled once and matched against many
+// items. A nil Matcher allows every item.
+type Matcher struct {
+	include []compiledRule
+	exclude []compiledRule
+}
+
+type compiledRule struct {
+	categories []string
+	title      *regexp.Regexp
+	authors    []string
+	keywords   []*regexp.Regexp
+}
+
+// Compile checks and compiles the filter. A nil filter compiles to a nil
+// Matcher.
+func (f *Filter) Compile() (*Matcher, error) {
+	if f.IsEmpty() {
+		return nil, nil
+	}
+	m := &Matcher{}
+	var err error
+	m.include, err = compileRules(f.Include)
+	if err != nil {
+		return nil, err
+	}
+	m.exclude, err = compileRules(f.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func compileRules(rules []Rule) ([]compiledRule, error) {
+	compiled := make([]compiledRule, len(rules))
+	for i, rule := range rules {
+		var err error
+		compiled[i], err = rule.compile()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return compiled, nil
+}
+
+func (r Rule) compile() (compiledRule, error) {
+	if len(r.Categories) == 0 && r.Title == "" && len(r.Authors) == 0 && len(r.Keywords) == 0 {
+		return compiledRule{}, ERR_EMPTY_RULE
+	}
+	c := compiledRule{categories: r.Categories, authors: r.Authors}
+	if r.Title != "" {
+		title, err := regexp.Compile(r.Title)
+		if err != nil {
+			return compiledRule{}, ERR_INVALID_TITLE_PATTERN
+		}
+		c.title = title
+	}
+	for _, keyword := range r.Keywords {
+		words := strings.Fields(keyword)
+		if len(words) == 0 {
+			continue
+		}
+		for i, word := range words {
+			words[i] = regexp.QuoteMeta(word)
+		}
+		c.keywords = append(c.keywords, regexp.MustCompile(`(?i)(^|\W)`+strings.Join(words, `\s+`)+`($|\W)`))
+	}
+	return c, nil
+}
+
+// Allows reports whether an item passes the filter.
+func (m *Matcher) Allows(item *gofeed.Item) bool {
+	if m == nil {
+		return true
+	}
+	fields := &itemFields{item: item}
+	for _, rule := range m.exclude {
+		if rule.matches(fields) {
+			return false
+		}
+	}
+	if len(m.include) == 0 {
+		return true
+	}
+	for _, rule := range m.include {
+		if rule.matches(fields) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether an item meets all of the rule's conditions.
+func (r compiledRule) matches(fields *itemFields) bool {
+	item := fields.item
+	if len(r.categories) > 0 && !anyEqual(r.categories, item.Categories) {
+		return false
+	}
+	if r.title != nil && !r.title.MatchString(item.Title) {
+		return false
+	}
+	if len(r.authors) > 0 && !anyEqual(r.authors, authors(item)) {
+		return false
+	}
+	if len(r.keywords) > 0 && !anyMatch(r.keywords, fields.text()) {
+		return false
+	}
+	return true
+}
+
+// itemFields extracts the text of an item once for all rules.
+type itemFields struct {
+	item      *gofeed.Item
+	plainText *string
+}
+
+// text returns the title, description and content of the item as plain text.
+func (f *itemFields) text() string {
+	if f.plainText == nil {
+		text := strings.Join([]string{f.item.Title, plainText(f.item.Description), plainText(f.item.Content)}, "\n")
+		f.plainText = &text
+	}
+	return *f.plainText
+}
+
+func anyMatch(patterns []*regexp.Regexp, text string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+func anyEqual(wanted []string, values []string) bool {
+	for _, w := range wanted {
+		for _, v := range values {
+			if strings.EqualFold(strings.TrimSpace(v), strings.TrimSpace(w)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// authors returns the names and addresses of an item's authors.
+func authors(item *gofeed.Item) []string {
+	people := append([]*gofeed.Person{item.Author}, item.Authors...)
+	values := []string{}
+	for _, person := range people {
+		if person == nil {
+			continue
+		}
+		if person.Name != "" {
+			values = append(values, person.Name)
+		}
+		if person.Email != "" {
+			values = append(values, person.Email)
+		}
+	}
+	return values
+}
+
+// plainText returns the text of item HTML, without markup or link addresses
+// that could match keywords.
+func plainText(s string) string {
+	if s == "" {
+		return ""
+	}
+	doc, err := html.Parse(strings.NewReader(s))
+	if err != nil {
+		return s
+	}
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+			sb.WriteString(" ")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return sb.String()
+}
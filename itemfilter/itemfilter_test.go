@@ -0,0 +1,109 @@
+package itemfilter
+
+import (
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+)
+
+func TestAllows(t *testing.T) {
+	item := &gofeed.Item{
+		Title:       "Release notes: Version 2.0",
+		Description: `<p>What's new in the <a href="https://example.com/kubernetes">operator</a>.</p>`,
+		Content:     `<p>The <em>Go</em> SDK now supports streaming.</p>`,
+		Categories:  []string{"Releases", " Go "},
+		Author:      &gofeed.Person{Name: "Ada Lovelace", Email: "ada@example.com"},
+		Authors:     []*gofeed.Person{{Name: "Charles Babbage"}},
+	}
+	tests := []struct {
+		name   string
+		filter *Filter
+		want   bool
+	}{
+		{"nil filter", nil, true},
+		{"empty filter", &Filter{}, true},
+		{"include by category ignoring case and space", &Filter{Include: []Rule{{Categories: []string{"go"}}}}, true},
+		{"include by other category", &Filter{Include: []Rule{{Categories: []string{"Rust"}}}}, false},
+		{"include by title pattern", &Filter{Include: []Rule{{Title: `^Release notes`}}}, true},
+		{"title pattern is case sensitive", &Filter{Include: []Rule{{Title: `^release notes`}}}, false},
+		{"include by author name", &Filter{Include: []Rule{{Authors: []string{"ada lovelace"}}}}, true},
+		{"include by author address", &Filter{Include: []Rule{{Authors: []string{"ADA@example.com"}}}}, true},
+		{"include by additional author", &Filter{Include: []Rule{{Authors: []string{"Charles Babbage"}}}}, true},
+		{"keyword in content", &Filter{Include: []Rule{{Keywords: []string{"streaming"}}}}, true},
+		{"keyword ignores case", &Filter{Include: []Rule{{Keywords: []string{"STREAMING"}}}}, true},
+		{"keyword matches whole words", &Filter{Include: []Rule{{Keywords: []string{"stream"}}}}, false},
+		{"keyword phrase across markup", &Filter{Include: []Rule{{Keywords: []string{"the go sdk"}}}}, true},
+		{"keyword in title", &Filter{Include: []Rule{{Keywords: []string{"version 2.0"}}}}, true},
+		{"keyword not in link address", &Filter{Include: []Rule{{Keywords: []string{"kubernetes"}}}}, false},
+		{"keyword is not a pattern", &Filter{Include: []Rule{{Keywords: []string{"version 2.."}}}}, false},
+		{"all conditions of a rule", &Filter{Include: []Rule{{Categories: []string{"Go"}, Authors: []string{"Grace Hopper"}}}}, false},
+		{"any of the include rules", &Filter{Include: []Rule{{Authors: []string{"Grace Hopper"}}, {Categories: []string{"Go"}}}}, true},
+		{"exclude by category", &Filter{Exclude: []Rule{{Categories: []string{"Releases"}}}}, false},
+		{"exclude not matching", &Filter{Exclude: []Rule{{Categories: []string{"Sponsored"}}}}, true},
+		{"exclude beats include", &Filter{Include: []Rule{{Categories: []string{"Go"}}}, Exclude: []Rule{{Title: `Version 2`}}}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m, err := test.filter.Compile()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := m.Allows(item); got != test.want {
+				t.Errorf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestAllowsItemWithoutAuthorsOrContent(t *testing.T) {
+	m, err := (&Filter{Exclude: []Rule{{Authors: []string{"Ada Lovelace"}}, {Keywords: []string{"sponsored"}}}}).Compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.Allows(&gofeed.Item{Title: "Untitled"}) {
+		t.Error("item without authors or content was excluded")
+	}
+}
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter *Filter
+		want   error
+	}{
+		{"nil", nil, nil},
+		{"valid", &Filter{Include: []Rule{{Title: `^\[Go\]`}}, Exclude: []Rule{{Keywords: []string{"sponsored"}}}}, nil},
+		{"empty include rule", &Filter{Include: []Rule{{}}}, ERR_EMPTY_RULE},
+		{"empty exclude rule", &Filter{Exclude: []Rule{{Categories: []string{}}}}, ERR_EMPTY_RULE},
+		{"invalid include title", &Filter{Include: []Rule{{Title: `(`}}}, ERR_INVALID_TITLE_PATTERN},
+		{"invalid exclude title", &Filter{Exclude: []Rule{{Categories: []string{"Go"}}, {Title: `[`}}}, ERR_INVALID_TITLE_PATTERN},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := test.filter.Compile()
+			if err != test.want {
+				t.Errorf("got error %v, want %v", err, test.want)
+			}
+			if err := test.filter.Validate(); err != test.want {
+				t.Errorf("Validate got error %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestCopy(t *testing.T) {
+	var nilFilter *Filter
+	if nilFilter.Copy() != nil {
+		t.Error("copy of nil filter is not nil")
+	}
+	original := &Filter{Include: []Rule{{Categories: []string{"Go"}, Title: "a", Authors: []string{"Ada"}, Keywords: []string{"sdk"}}}}
+	copied := original.Copy()
+	original.Include[0].Categories[0] = "Rust"
+	original.Include[0].Authors[0] = "Grace"
+	original.Include[0].Keywords[0] = "cli"
+	original.Include = append(original.Include, Rule{Title: "b"})
+	rule := copied.Include[0]
+	if len(copied.Include) != 1 || rule.Categories[0] != "Go" || rule.Authors[0] != "Ada" || rule.Keywords[0] != "sdk" || rule.Title != "a" {
+		t.Errorf("copy changed with the original: %+v", copied)
+	}
+}
@@ -8,7 +8,8 @@ import (
 	"github.com/apex/log"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
-	"gjhr.me/newsletter/data/subscription"
+	"gjhr.me/newsletter/bouncehandler"
+	"gjhr.me/newsletter/utils/snsmessage"
 )
 
 func main() {
@@ -27,24 +28,11 @@ func Handle(ctx context.Context, event events.SNSEvent) {
 	}
 	hasErrored := false
 	for _, record := range event.Records {
-		log.Debug(record.SNS.Message)
-		var message Message
-		err := json.Unmarshal([]byte(record.SNS.Message), &message)
+		err := bouncehandler.HandleSNS(snsmessage.FromLambda(record.SNS))
 		if err != nil {
+			log.WithError(err).Error("Failed to handle SNS record")
 			hasErrored = true
 		}
-		// If permanent hard bounce or abuse complaint
-		if (message.Bounce != nil && message.Bounce.BounceType == BounceTypePermanent) ||
-			(message.Complaint != nil && message.Complaint.ComplaintFeedbackType != "not-spam") {
-			// Permanently unsubscribe email from all lists
-			for _, recepient := range message.Mail.Destination {
-				err := subscription.DeleteAllForEmail(recepient)
-				if err != nil {
-					log.Warnf("Failed to delete all subscriptions for %v", recepient)
-				}
-				log.Infof("Deleted all subscriptions for %v", recepient)
-			}
-		}
 	}
 	if hasErrored {
 		panic("Failure while handling one or more SNS records")
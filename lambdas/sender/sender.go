@@ -4,20 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"html/template"
-	"io"
 	"os"
-	"strings"
 
 	"github.com/apex/log"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws/arn"
-	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/sqs"
 	"gjhr.me/newsletter/data/mail"
-	"gjhr.me/newsletter/emailsender"
 	"gjhr.me/newsletter/providers/aws"
+	"gjhr.me/newsletter/sender"
 )
 
 func main() {
@@ -44,41 +40,14 @@ func Handle(ctx context.Context, event events.SQSEvent) error {
 			return err
 		}
 
-		logger.Infof("Processing mail for '%v' with subject '%v'...", mail.To, mail.Subject)
-
-		// Download template
-		logger.Debugf("Downloading template from bucket '%v' key '%v'", mail.TemplateBucket, mail.TemplateKey)
-		res, err := aws.S3().GetObject(&s3.GetObjectInput{
-			Bucket: &mail.TemplateBucket,
-			Key:    &mail.TemplateKey,
-		})
-		if err != nil {
-			logger.WithError(err).Error("Error downloading template")
-			return err
-		}
-		buf := new(strings.Builder)
-		_, err = io.Copy(buf, res.Body)
-		if err != nil {
-			logger.WithError(err).Error("Error reading template")
-			return err
-		}
-		logger.Debugf("Parsing template:\n%v", buf.String())
-		t, err := template.New("body").Parse(buf.String())
-		if err != nil {
-			logger.WithError(err).Error("Error parsing template")
-			return err
-		}
-
 		// Prepare for message deletion
 		// MUST be done before sending mail to avoid possiblity of bugs causing multiple sends
 		queueArn, err := arn.Parse(record.EventSourceARN)
 		queueUrl := fmt.Sprintf("https://sqs.%v.amazonaws.com/%v/%v", queueArn.Region, queueArn.AccountID, queueArn.Resource)
 
 		// Send mail
-		logger.Debugf("Sending mail to '%v'", mail.To)
-		err = emailsender.SendMail(mail.To, mail.From, mail.ReplyTo, mail.Subject, t, mail.TemplateValues)
+		err = sender.Send(mail, logger)
 		if err != nil {
-			logger.WithError(err).Error("Error sending mail")
 			return err
 		}
 
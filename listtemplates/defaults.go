@@ -0,0 +1,280 @@
+package listtemplates
+
+// Default page templates, executed with the frontend's page content.
+var defaultPages = map[string]string{
+	HEAD: `
+	<!doctype html>
+	<html lang=en>
+	<head>
+	<meta charset=utf-8>
+	<meta http-equiv=x-ua-compatible content="IE=edge">
+	<meta name=viewport content="width=device-width,initial-scale=1">
+	<title>{{.Title}}</title>
+	<style type=text/css>body{margin:auto;max-width:650px;line-height:1.6;font-size:18px;color:#444;padding:0 10px}h1,h2,h3{line-height:1.2}a,a:visited{color:#333;text-decoration-color:#19c7e5;text-decoration-thickness:2px}footer{margin-top:10px}time{font-style:italic}figure{margin:0}figcaption{text-align:center;font-size:.7em}hr{width:80%;border:1px solid #d3d3d3}.flex-spaced{display:flex;flex-wrap:wrap;align-items:center;justify-content:space-around}.svg-icon{width:16px;height:16px;fill:#444}.svg-inline{display:none}#main-header div{flex-grow:100}#main-header div a{margin-left:5px}#main-footer div{margin-left:5px}#about-short{display:flex;flex-wrap:wrap;align-items:center;justify-content:center;margin:20px 0;padding:10px;gap:10px;border-radius:10px;border:1px solid #d3d3d3}#about-short img{border-radius:50%}#about-short div{flex-grow:1;width:75%;min-width:75%;max-width:100%}#about-short form{width:100%;display:flex;justify-content:center}input,select{border:1px solid #d3d3d3;padding:10px;margin:5px;border-radius:5px}.about-short-submit{color:#fff;background-color:#0f9afc}img{max-width:100%}pre{white-space:pre-wrap;font-size:.75em}.small{font-size:.7em}.index-list li{padding-bottom:.7em}ul{list-style-type:none;padding:0;line-height:1.2}ul li:not(:last-child){margin-bottom:.5em}</style>
+	</head>
+	
+	<body>
+		<h1>{{.Title}}</h1>
+		<div id="content">`,
+
+	FOOT: `
+		</div>
+	</body>
+	</html>`,
+
+	INDEX: `
+	{{ template "head" . }}
+	<form method="get" action="/subscribe">
+		<input type="text" id="email" name="email">
+		<select id="delivery" name="delivery">
+			<option value="" selected>List default ({{ .List.DeliveryMode }})</option>
+			<option value="immediate">Every post</option>
+			<option value="daily">Daily digest</option>
+			<option value="weekly">Weekly digest</option>
+		</select>
+		<input type="submit" value="Subscribe">
+		{{ if gt (len .List.Feeds) 1 }}
+		<p>
+			{{ range .List.Feeds }}
+			<label><input type="checkbox" name="feed" value="{{ .Key }}"{{ if $.FeedChosen .Key }} checked{{ end }}> {{ .DisplayName }}</label>
+			{{ end }}
+		</p>
+		{{ end }}
+	</form>
+	<p>
+		{{.List.Description}}
+	</p>
+	<details>
+		<summary>Unsubscribe</summary>
+		<form method="get" action="/unsubscribe">
+			<input type="text" id="email" name="email">
+			<input type="submit" value="Unsubscribe">
+		</form>
+	</details>
+	{{ template "foot" . }}
+	`,
+
+	SUBSCRIBE: `
+	{{ template "head" . }}
+	Please follow the verification link sent to '{{ .Subscription.Email }}' to confirm your subscription.
+	{{ template "foot" . }}
+	`,
+
+	UNSUBSCRIBE: `
+	{{ template "head" . }}
+	You have unsubscribed from '{{ .List.Name }}'.
+	{{ template "foot" . }}
+	`,
+
+	UNSUBSCRIBE_REQUESTED: `
+	{{ template "head" . }}
+	If you are subscribed to '{{ .List.Name }}' you will shortly receive an email with a link to unsubscribe.
+	{{ template "foot" . }}
+	`,
+
+	VERIFY: `
+	{{ template "head" . }}
+	Subscription verified!
+	{{ template "foot" . }}
+	`,
+
+	PREFERENCES: `
+	{{ template "head" . }}
+	{{ if .Err }}<p>{{ .Err }}</p>{{ end }}
+	{{ if .Message }}<p>{{ .Message }}</p>{{ end }}
+	{{ with .Subscription }}
+	{{ if not .Subscribed.IsZero }}<p>Subscribed to '{{ $.List.Name }}' since <time>{{ .Subscribed.Format "2 January 2006" }}</time>.</p>{{ end }}
+	<form method="post" action="/preferences?token={{ $.Token }}">
+		<p>
+			<label for="email">Address</label>
+			<input type="email" id="email" name="email" value="{{ .Email }}">
+		</p>
+		<p>
+			<label for="delivery">Send</label>
+			{{ $delivery := .Delivery }}
+			<select id="delivery" name="delivery">
+				<option value=""{{ if eq $delivery "" }} selected{{ end }}>List default ({{ $.List.DeliveryMode }})</option>
+				<option value="immediate"{{ if eq $delivery "immediate" }} selected{{ end }}>Every post</option>
+				<option value="daily"{{ if eq $delivery "daily" }} selected{{ end }}>Daily digest</option>
+				<option value="weekly"{{ if eq $delivery "weekly" }} selected{{ end }}>Weekly digest</option>
+			</select>
+		</p>
+		{{ if gt (len $.List.Feeds) 1 }}
+		<fieldset>
+			<legend>Feeds</legend>
+			{{ range $.List.Feeds }}
+			<label><input type="checkbox" name="feed" value="{{ .Key }}"{{ if $.Subscription.WantsFeed .Key }} checked{{ end }}> {{ .DisplayName }}</label><br>
+			{{ end }}
+		</fieldset>
+		{{ end }}
+		<p>
+			<label for="paused_until">Pause until</label>
+			<input type="date" id="paused_until" name="paused_until" value="{{ if not .PausedUntil.IsZero }}{{ .PausedUntil.Format "2006-01-02" }}{{ end }}">
+		</p>
+		<input type="submit" value="Save">
+	</form>
+	{{ end }}
+	{{ template "foot" . }}
+	`,
+
+	ERROR: `
+	{{ template "head" . }}
+	Unexpected error has occured: {{.Err}}
+	{{ template "foot" . }}
+	`,
+}
+
+// Default email templates.
+var defaultEmails = map[string]string{
+	// Executed with struct{ List, VerificationLink string }
+	VERIFICATION_EMAIL: `
+	<!DOCTYPE html>
+	<html lang="en" xmlns="http://www.w3.org/1999/xhtml" xmlns:o="urn:schemas-microsoft-com:office:office">
+	<head>
+			<meta charset="UTF-8">
+			<meta name="viewport" content="width=device-width,initial-scale=1">
+			<meta name="x-apple-disable-message-reformatting">
+			<title></title>
+			<style>
+					body {font-family: Arial, sans-serif;}
+			</style>
+	</head>
+	<body>
+		<h3>Please verify your email</h3>
+		<p>
+			To complete your subscription to {{ .List }}, please click <a href="{{ .VerificationLink }}">this link</a> or browse to the URL below.
+		</p>
+		<p>
+			{{ .VerificationLink }}
+		</p>
+	</body>
+	</html>
+	`,
+
+	// Executed with struct{ List, UnsubscribeLink string }
+	UNSUBSCRIBE_EMAIL: `
+	<!DOCTYPE html>
+	<html lang="en" xmlns="http://www.w3.org/1999/xhtml" xmlns:o="urn:schemas-microsoft-com:office:office">
+	<head>
+			<meta charset="UTF-8">
+			<meta name="viewport" content="width=device-width,initial-scale=1">
+			<meta name="x-apple-disable-message-reformatting">
+			<title></title>
+			<style>
+					body {font-family: Arial, sans-serif;}
+			</style>
+	</head>
+	<body>
+		<h3>Unsubscribe from {{ .List }}</h3>
+		<p>
+			To stop receiving emails from {{ .List }}, please click <a href="{{ .UnsubscribeLink }}">this link</a> or browse to the URL below.
+		</p>
+		<p>
+			{{ .UnsubscribeLink }}
+		</p>
+		<p>
+			If you did not ask to unsubscribe you can ignore this email.
+		</p>
+	</body>
+	</html>
+	`,
+
+	// Layout feed items are rendered into by the issue package. Elements are
+	// filled by ID and the result is executed with mail.MailTemplateValues.
+	ISSUE_LAYOUT: `<!DOCTYPE html>
+<html lang="en" xmlns="http://www.w3.org/1999/xhtml" xmlns:o="urn:schemas-microsoft-com:office:office">
+<head>
+	<meta charset="UTF-8">
+	<meta name="viewport" content="width=device-width,initial-scale=1">
+	<meta name="x-apple-disable-message-reformatting">
+	<title></title>
+</head>
+<body style="margin:0;padding:0;background-color:#f4f4f4;">
+	<table role="presentation" width="100%" cellspacing="0" cellpadding="0" border="0" style="background-color:#f4f4f4;">
+		<tr>
+			<td align="center" style="padding:20px 10px;">
+				<table role="presentation" width="100%" cellspacing="0" cellpadding="0" border="0" style="max-width:650px;background-color:#ffffff;font-family:Arial,sans-serif;font-size:16px;line-height:1.6;color:#444444;">
+					<tr>
+						<td style="padding:10px 20px;border-bottom:1px solid #d3d3d3;font-size:14px;color:#888888;">
+							<span id="issue-list-name"></span>
+						</td>
+					</tr>
+					<tr>
+						<td style="padding:20px 20px 0 20px;">
+							<h1 style="margin:0;font-size:26px;line-height:1.2;color:#333333;"><a id="issue-link" href="" style="color:#333333;text-decoration:none;"><span id="issue-title"></span></a></h1>
+							<p style="margin:5px 0 0 0;font-size:14px;color:#888888;"><span id="issue-author"></span> <time id="issue-date" style="font-style:italic;"></time></p>
+						</td>
+					</tr>
+					<tr>
+						<td id="issue-content" style="padding:10px 20px;"></td>
+					</tr>
+					<tr>
+						<td style="padding:10px 20px 20px 20px;">
+							<a id="issue-read-online" href="" style="color:#333333;">Read online</a>
+						</td>
+					</tr>
+					<tr>
+						<td style="padding:10px 20px;border-top:1px solid #d3d3d3;font-size:12px;color:#888888;text-align:center;">
+							You are receiving this email because you subscribed to <span id="issue-footer-list-name"></span>.
+							<a id="issue-preferences" href="{{ .PreferencesLink }}" style="color:#888888;">Preferences</a>
+							<a id="issue-unsubscribe" href="{{ .UnsubscribeLink }}" style="color:#888888;">Unsubscribe</a>
+						</td>
+					</tr>
+				</table>
+			</td>
+		</tr>
+	</table>
+</body>
+</html>
+`,
+
+	// Layout of digests. The element with the digest item ID is repeated for
+	// each item and filled like an issue, the item's IDs suffixed with its
+	// index.
+	DIGEST_LAYOUT: `<!DOCTYPE html>
+<html lang="en" xmlns="http://www.w3.org/1999/xhtml" xmlns:o="urn:schemas-microsoft-com:office:office">
+<head>
+	<meta charset="UTF-8">
+	<meta name="viewport" content="width=device-width,initial-scale=1">
+	<meta name="x-apple-disable-message-reformatting">
+	<title></title>
+</head>
+<body style="margin:0;padding:0;background-color:#f4f4f4;">
+	<table role="presentation" width="100%" cellspacing="0" cellpadding="0" border="0" style="background-color:#f4f4f4;">
+		<tr>
+			<td align="center" style="padding:20px 10px;">
+				<table role="presentation" width="100%" cellspacing="0" cellpadding="0" border="0" style="max-width:650px;background-color:#ffffff;font-family:Arial,sans-serif;font-size:16px;line-height:1.6;color:#444444;">
+					<tr>
+						<td style="padding:10px 20px;border-bottom:1px solid #d3d3d3;font-size:14px;color:#888888;">
+							<span id="issue-list-name"></span>
+						</td>
+					</tr>
+					<tr>
+						<td style="padding:20px 20px 0 20px;">
+							<h1 style="margin:0;font-size:26px;line-height:1.2;color:#333333;" id="digest-title"></h1>
+							<p style="margin:5px 0 0 0;font-size:14px;color:#888888;"><time id="digest-date" style="font-style:italic;"></time></p>
+						</td>
+					</tr>
+					<tr id="digest-item">
+						<td style="padding:20px 20px 10px 20px;border-bottom:1px solid #d3d3d3;">
+							<h2 style="margin:0;font-size:22px;line-height:1.2;color:#333333;"><a id="issue-link" href="" style="color:#333333;text-decoration:none;"><span id="issue-title"></span></a></h2>
+							<p style="margin:5px 0 0 0;font-size:14px;color:#888888;"><span id="issue-author"></span> <time id="issue-date" style="font-style:italic;"></time></p>
+							<div id="issue-content"></div>
+							<p style="margin:0;"><a id="issue-read-online" href="" style="color:#333333;">Read online</a></p>
+						</td>
+					</tr>
+					<tr>
+						<td style="padding:10px 20px;font-size:12px;color:#888888;text-align:center;">
+							You are receiving this email because you subscribed to <span id="issue-footer-list-name"></span>.
+							<a id="issue-preferences" href="{{ .PreferencesLink }}" style="color:#888888;">Preferences</a>
+							<a id="issue-unsubscribe" href="{{ .UnsubscribeLink }}" style="color:#888888;">Unsubscribe</a>
+						</td>
+					</tr>
+				</table>
+			</td>
+		</tr>
+	</table>
+</body>
+</html>
+`,
+}
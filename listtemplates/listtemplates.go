@@ -0,0 +1,198 @@
+package listtemplates
+
+import (
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gjhr.me/newsletter/data/list"
+	"gjhr.me/newsletter/emailrenderer"
+	"gjhr.me/newsletter/providers/storage"
+)
+
+// Names of the templates a list can override.
+const (
+	HEAD                  = "head"
+	FOOT                  = "foot"
+	INDEX                 = "index"
+	SUBSCRIBE             = "subscribe"
+	UNSUBSCRIBE           = "unsubscribe"
+	UNSUBSCRIBE_REQUESTED = "unsubscribe-requested"
+	VERIFY                = "verify"
+	PREFERENCES           = "preferences"
+	ERROR                 = "error"
+	VERIFICATION_EMAIL    = "verification-email"
+	UNSUBSCRIBE_EMAIL     = "unsubscribe-email"
+	ISSUE_LAYOUT          = "issue-layout"
+	DIGEST_LAYOUT         = "digest-layout"
+)
+
+// ID of the element feed item content is injected into by issue layouts.
+const ISSUE_CONTENT_ID = "issue-content"
+
+// Width in pixels of the content of the default layouts, images in content
+// are sized to fit it.
+const ISSUE_CONTENT_WIDTH = 610
+
+// ID of the element repeated for each item by digest layouts.
+const DIGEST_ITEM_ID = "digest-item"
+
+// Elements layouts must contain.
+var requiredIDs = map[string][]string{
+	ISSUE_LAYOUT:  {ISSUE_CONTENT_ID},
+	DIGEST_LAYOUT: {DIGEST_ITEM_ID, ISSUE_CONTENT_ID},
+}
+
+// How long template bodies fetched from storage are reused for.
+const cacheTTL = 5 * time.Minute
+
+type cachedBody struct {
+	body    string
+	fetched time.Time
+}
+
+var cacheMu sync.Mutex
+var cache = map[string]cachedBody{}
+
+// Names returns the names of every template that can be overridden.
+func Names() []string {
+	names := []string{}
+	for name := range defaultPages {
+		names = append(names, name)
+	}
+	for name := range defaultEmails {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Load parses the default templates with the list's overrides, stored in the
+// template store, parsed over the top. A nil list loads the defaults.
+func Load(l *list.List) (*template.Template, error) {
+	t, err := parseDefaults()
+	if err != nil {
+		return nil, err
+	}
+	if l == nil {
+		return t, nil
+	}
+	for _, name := range sortedKeys(l.TemplateOverrides) {
+		err = parseOverride(t, name, l.TemplateOverrides[name], true)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Source returns the unparsed source of a template for a list, which is the
+// list's override if it has one.
+func Source(l *list.List, name string) (string, error) {
+	key, ok := l.TemplateOverrides[name]
+	if !ok {
+		if body, ok := defaultEmails[name]; ok {
+			return body, nil
+		}
+		return defaultPages[name], nil
+	}
+	return fetch(key, true)
+}
+
+// Validate checks that every override of a list names a known template and
+// parses, bypassing the cache. It should be called before a list is saved.
+func Validate(l *list.List) error {
+	t, err := parseDefaults()
+	if err != nil {
+		return err
+	}
+	for _, name := range sortedKeys(l.TemplateOverrides) {
+		err = parseOverride(t, name, l.TemplateOverrides[name], false)
+		if err != nil {
+			return err
+		}
+	}
+	for _, name := range sortedKeys(l.TemplateOverrides) {
+		ids, ok := requiredIDs[name]
+		if !ok {
+			continue
+		}
+		body, err := fetch(l.TemplateOverrides[name], false)
+		if err != nil {
+			return err
+		}
+		r, err := emailrenderer.NewRenderer(strings.NewReader(body))
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if !r.HasID(id) {
+				return fmt.Errorf("Template '%v' must contain an element with id '%v'", name, id)
+			}
+		}
+	}
+	return nil
+}
+
+func parseDefaults() (*template.Template, error) {
+	t := template.New("root")
+	for _, defaults := range []map[string]string{defaultPages, defaultEmails} {
+		for name, body := range defaults {
+			_, err := t.New(name).Parse(body)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return t, nil
+}
+
+func parseOverride(t *template.Template, name string, key string, cached bool) error {
+	if !isKnown(name) {
+		return fmt.Errorf("Unknown template '%v', expected one of %v", name, Names())
+	}
+	body, err := fetch(key, cached)
+	if err != nil {
+		return fmt.Errorf("Failed to load template '%v' from '%v': %w", name, key, err)
+	}
+	_, err = t.New(name).Parse(body)
+	if err != nil {
+		return fmt.Errorf("Failed to parse template '%v' from '%v': %w", name, key, err)
+	}
+	return nil
+}
+
+func fetch(key string, cached bool) (string, error) {
+	cacheMu.Lock()
+	entry, ok := cache[key]
+	cacheMu.Unlock()
+	if cached && ok && time.Since(entry.fetched) < cacheTTL {
+		return entry.body, nil
+	}
+	body, err := storage.Templates().Get(key)
+	if err != nil {
+		return "", err
+	}
+	cacheMu.Lock()
+	cache[key] = cachedBody{body: body, fetched: time.Now()}
+	cacheMu.Unlock()
+	return body, nil
+}
+
+func isKnown(name string) bool {
+	_, page := defaultPages[name]
+	_, email := defaultEmails[name]
+	return page || email
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,69 @@
+package mailqueue
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"gjhr.me/newsletter/data/mail"
+)
+
+// Queue hands mail over from the feed reader to the sender.
+type Queue interface {
+	// Enqueue queues a mail. Mails sharing a group are delivered in order.
+	// Mails with the deduplication ID of another mail queued in the last five
+	// minutes may be dropped, whatever their group.
+	Enqueue(m mail.Mail, groupID string, deduplicationID string) error
+}
+
+// DeduplicationID identifies the mail of an issue to one address, so the same
+// mail is not queued twice but every issue reaches each subscriber.
+func DeduplicationID(issueID string, email string) string {
+	sum := sha256.Sum256([]byte(issueID + "\n" + email))
+	return hex.EncodeToString(sum[:])
+}
+
+// SQSQueue is a Queue backed by a FIFO SQS queue.
+type SQSQueue struct {
+	client *sqs.SQS
+	url    string
+}
+
+func NewSQSQueue(client *sqs.SQS, url string) *SQSQueue {
+	return &SQSQueue{client: client, url: url}
+}
+
+func (q *SQSQueue) Enqueue(m mail.Mail, groupID string, deduplicationID string) error {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = q.client.SendMessage(&sqs.SendMessageInput{
+		MessageDeduplicationId: aws.String(deduplicationID),
+		MessageGroupId:         aws.String(groupID),
+		QueueUrl:               aws.String(q.url),
+		MessageBody:            aws.String(string(body)),
+	})
+	return err
+}
+
+// MemoryQueue is an in process Queue. Mails are read from the channel
+// returned by Receive.
+type MemoryQueue struct {
+	mails chan mail.Mail
+}
+
+func NewMemoryQueue(size int) *MemoryQueue {
+	return &MemoryQueue{mails: make(chan mail.Mail, size)}
+}
+
+func (q *MemoryQueue) Enqueue(m mail.Mail, groupID string, deduplicationID string) error {
+	q.mails <- m
+	return nil
+}
+
+func (q *MemoryQueue) Receive() <-chan mail.Mail {
+	return q.mails
+}
@@ -0,0 +1,23 @@
+package mailqueue
+
+import "testing"
+
+func TestDeduplicationID(t *testing.T) {
+	id := DeduplicationID("list/issue-1", "reader@example.com")
+	if len(id) > 128 {
+		t.Errorf("ID of %v characters is over the SQS limit of 128", len(id))
+	}
+	if again := DeduplicationID("list/issue-1", "reader@example.com"); again != id {
+		t.Errorf("got %v for the same mail, want %v", again, id)
+	}
+	others := []struct{ issueID, email string }{
+		{"list/issue-2", "reader@example.com"},
+		{"list/issue-1", "other@example.com"},
+		{"list/issue-1\nreader", "example.com"},
+	}
+	for _, other := range others {
+		if DeduplicationID(other.issueID, other.email) == id {
+			t.Errorf("%q to %q shares an ID with issue-1 to reader@example.com", other.issueID, other.email)
+		}
+	}
+}
@@ -7,19 +7,57 @@ import (
 var conf Config
 
 type Config struct {
-	ListsTable         string
-	SubscriptionsTable string
-	TemplateBucket     string
-	SenderQueueUrl     string
-	LogLevel           string
+	ListsTable          string
+	SubscriptionsTable  string
+	ClicksTable         string
+	OpensTable          string
+	MailEventsTable     string
+	SuppressionsTable   string
+	PendingTable        string
+	TemplateBucket      string
+	SenderQueueUrl      string
+	LogLevel            string
+	BaseUrlScheme       string
+	StorageBackend      string
+	StorageDirectory    string
+	MailTransport       string
+	SesConfigurationSet string
+	SmtpAddress         string
+	SmtpUsername        string
+	SmtpPassword        string
+	SpoolDirectory      string
+	SigningKeys         string
+	SnsTopicArns        string
 }
 
 func init() {
 	viper.BindEnv("ListsTable", "NEWSLETTER_LISTS_TABLE")
 	viper.BindEnv("SubscriptionsTable", "NEWSLETTER_SUBSCRIPTIONS_TABLE")
+	viper.BindEnv("ClicksTable", "NEWSLETTER_CLICKS_TABLE")
+	viper.BindEnv("OpensTable", "NEWSLETTER_OPENS_TABLE")
+	viper.BindEnv("MailEventsTable", "NEWSLETTER_MAIL_EVENTS_TABLE")
+	viper.BindEnv("SuppressionsTable", "NEWSLETTER_SUPPRESSIONS_TABLE")
+	viper.BindEnv("PendingTable", "NEWSLETTER_PENDING_TABLE")
 	viper.BindEnv("TemplateBucket", "NEWSLETTER_TEMPLATE_BUCKET")
 	viper.BindEnv("LogLevel", "NEWSLETTER_LOG_LEVEL")
+	viper.BindEnv("BaseUrlScheme", "NEWSLETTER_BASE_URL_SCHEME")
+	viper.SetDefault("BaseUrlScheme", "https")
 	viper.BindEnv("SenderQueueUrl", "NEWSLETTER_SENDER_QUEUE_URL")
+	viper.BindEnv("StorageBackend", "NEWSLETTER_STORAGE_BACKEND")
+	viper.SetDefault("StorageBackend", "dynamo")
+	viper.BindEnv("StorageDirectory", "NEWSLETTER_STORAGE_DIRECTORY")
+	viper.SetDefault("StorageDirectory", ".newsletter")
+	viper.BindEnv("MailTransport", "NEWSLETTER_MAIL_TRANSPORT")
+	viper.SetDefault("MailTransport", "ses")
+	viper.BindEnv("SesConfigurationSet", "NEWSLETTER_SES_CONFIGURATION_SET")
+	viper.BindEnv("SmtpAddress", "NEWSLETTER_SMTP_ADDRESS")
+	viper.SetDefault("SmtpAddress", "localhost:2500")
+	viper.BindEnv("SmtpUsername", "NEWSLETTER_SMTP_USERNAME")
+	viper.BindEnv("SmtpPassword", "NEWSLETTER_SMTP_PASSWORD")
+	viper.BindEnv("SnsTopicArns", "NEWSLETTER_SNS_TOPIC_ARNS")
+	viper.BindEnv("SpoolDirectory", "NEWSLETTER_SPOOL_DIRECTORY")
+	viper.SetDefault("SpoolDirectory", "spool")
+	viper.BindEnv("SigningKeys", "NEWSLETTER_SIGNING_KEYS")
 	err := viper.Unmarshal(&conf)
 	if err != nil {
 		panic(err)
@@ -29,3 +67,9 @@ func init() {
 func Get() Config {
 	return conf
 }
+
+// Set replaces the configuration, for binaries which take settings from flags
+// rather than the environment.
+func Set(c Config) {
+	conf = c
+}
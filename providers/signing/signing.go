@@ -0,0 +1,58 @@
+package signing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/apex/log"
+	"gjhr.me/newsletter/providers/config"
+	"gjhr.me/newsletter/utils/consterror"
+	"gjhr.me/newsletter/utils/signedtoken"
+)
+
+const ERR_NO_SIGNING_KEYS = consterror.ConstError("No signing keys configured, set NEWSLETTER_SIGNING_KEYS")
+
+var signer *signedtoken.Signer
+var signerErr error
+
+func init() {
+	keys := config.Get().SigningKeys
+	if keys == "" {
+		signerErr = ERR_NO_SIGNING_KEYS
+		return
+	}
+	signer, signerErr = signedtoken.NewSigner(keys)
+}
+
+// Check returns why links cannot be signed, if they cannot. Processes which
+// sign or verify links call it on start up so misconfiguration fails loudly
+// rather than breaking every link.
+func Check() error {
+	return signerErr
+}
+
+// UseEphemeralKey signs with a random key when none is configured. Links
+// signed with it only work within this process, so it is only for local runs
+// where one process both signs and verifies them.
+func UseEphemeralKey() error {
+	if signer != nil {
+		return nil
+	}
+	log.Warn("No signing keys configured, generating an ephemeral key")
+	secret := make([]byte, 32)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return err
+	}
+	signer, signerErr = signedtoken.NewSigner("ephemeral:" + hex.EncodeToString(secret))
+	return signerErr
+}
+
+// Signer returns the configured signer, panicking if there is none as links
+// would otherwise be signed with a key no other process knows.
+func Signer() *signedtoken.Signer {
+	if signer == nil {
+		panic(signerErr)
+	}
+	return signer
+}
@@ -0,0 +1,173 @@
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"gjhr.me/newsletter/data/clicks"
+	"gjhr.me/newsletter/data/list"
+	"gjhr.me/newsletter/data/mailevents"
+	"gjhr.me/newsletter/data/opens"
+	"gjhr.me/newsletter/data/pending"
+	"gjhr.me/newsletter/data/subscription"
+	"gjhr.me/newsletter/data/suppression"
+	"gjhr.me/newsletter/data/templates"
+	"gjhr.me/newsletter/providers/aws"
+	"gjhr.me/newsletter/providers/config"
+)
+
+var lists list.ListStore
+var subscriptions subscription.SubscriptionStore
+var templateStore templates.TemplateStore
+var clickStore clicks.ClickStore
+var openStore opens.OpenStore
+var mailEvents mailevents.MailEventStore
+var suppressions suppression.SuppressionStore
+var pendingItems pending.PendingStore
+
+func init() {
+	conf := config.Get()
+	err := Use(conf.StorageBackend, conf.StorageDirectory)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Use switches every store to the given backend. The directory is only used
+// by the file backend.
+func Use(backend string, directory string) error {
+	conf := config.Get()
+	switch backend {
+	case "dynamo":
+		lists = list.NewDynamoListStore(aws.Dynamo().Table(conf.ListsTable))
+		subscriptions = subscription.NewDynamoSubscriptionStore(aws.Dynamo().Table(conf.SubscriptionsTable))
+		templateStore = templates.NewS3TemplateStore(aws.S3(), conf.TemplateBucket)
+		clickStore = clicks.NewDynamoClickStore(aws.Dynamo().Table(conf.ClicksTable))
+		openStore = opens.NewDynamoOpenStore(aws.Dynamo().Table(conf.OpensTable))
+		mailEvents = mailevents.NewDynamoMailEventStore(aws.Dynamo().Table(conf.MailEventsTable))
+		suppressions = suppression.NewDynamoSuppressionStore(aws.Dynamo().Table(conf.SuppressionsTable))
+		pendingItems = pending.NewDynamoPendingStore(aws.Dynamo().Table(conf.PendingTable))
+	case "memory":
+		lists = list.NewMemoryListStore()
+		subscriptions = subscription.NewMemorySubscriptionStore()
+		templateStore = templates.NewMemoryTemplateStore()
+		clickStore = clicks.NewMemoryClickStore()
+		openStore = opens.NewMemoryOpenStore()
+		mailEvents = mailevents.NewMemoryMailEventStore()
+		suppressions = suppression.NewMemorySuppressionStore()
+		pendingItems = pending.NewMemoryPendingStore()
+	case "file":
+		fileLists, err := list.NewFileListStore(filepath.Join(directory, "lists.json"))
+		if err != nil {
+			return err
+		}
+		fileSubscriptions, err := subscription.NewFileSubscriptionStore(filepath.Join(directory, "subscriptions.json"))
+		if err != nil {
+			return err
+		}
+		fileClicks, err := clicks.NewFileClickStore(filepath.Join(directory, "clicks.json"))
+		if err != nil {
+			return err
+		}
+		fileOpens, err := opens.NewFileOpenStore(filepath.Join(directory, "opens.json"))
+		if err != nil {
+			return err
+		}
+		fileMailEvents, err := mailevents.NewFileMailEventStore(filepath.Join(directory, "mailevents.json"))
+		if err != nil {
+			return err
+		}
+		fileSuppressions, err := suppression.NewFileSuppressionStore(filepath.Join(directory, "suppressions.json"))
+		if err != nil {
+			return err
+		}
+		filePending, err := pending.NewFilePendingStore(filepath.Join(directory, "pending.json"))
+		if err != nil {
+			return err
+		}
+		lists = fileLists
+		subscriptions = fileSubscriptions
+		clickStore = fileClicks
+		openStore = fileOpens
+		mailEvents = fileMailEvents
+		suppressions = fileSuppressions
+		pendingItems = filePending
+		templateStore = templates.NewDirectoryTemplateStore(filepath.Join(directory, "templates"))
+	default:
+		return fmt.Errorf("Unknown storage backend '%v'", backend)
+	}
+	return nil
+}
+
+func Lists() list.ListStore {
+	return lists
+}
+
+func Subscriptions() subscription.SubscriptionStore {
+	return subscriptions
+}
+
+func Templates() templates.TemplateStore {
+	return templateStore
+}
+
+func Clicks() clicks.ClickStore {
+	return clickStore
+}
+
+func Opens() opens.OpenStore {
+	return openStore
+}
+
+func MailEvents() mailevents.MailEventStore {
+	return mailEvents
+}
+
+func Suppressions() suppression.SuppressionStore {
+	return suppressions
+}
+
+func Pending() pending.PendingStore {
+	return pendingItems
+}
+
+// SetLists replaces the list store used by the application, e.g. to share a
+// single in memory store between components running in the same process.
+func SetLists(store list.ListStore) {
+	lists = store
+}
+
+// SetSubscriptions replaces the subscription store used by the application.
+func SetSubscriptions(store subscription.SubscriptionStore) {
+	subscriptions = store
+}
+
+// SetTemplates replaces the template store used by the application.
+func SetTemplates(store templates.TemplateStore) {
+	templateStore = store
+}
+
+// SetClicks replaces the click store used by the application.
+func SetClicks(store clicks.ClickStore) {
+	clickStore = store
+}
+
+// SetOpens replaces the open store used by the application.
+func SetOpens(store opens.OpenStore) {
+	openStore = store
+}
+
+// SetMailEvents replaces the mail event store used by the application.
+func SetMailEvents(store mailevents.MailEventStore) {
+	mailEvents = store
+}
+
+// SetSuppressions replaces the suppression store used by the application.
+func SetSuppressions(store suppression.SuppressionStore) {
+	suppressions = store
+}
+
+// SetPending replaces the pending digest item store used by the application.
+func SetPending(store pending.PendingStore) {
+	pendingItems = store
+}
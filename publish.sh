@@ -19,6 +19,11 @@ fi
 
 . "$UTILS_PATH"
 
+[[ -n "$NEWSLETTER_SIGNING_KEYS" ]] || _error "NEWSLETTER_SIGNING_KEYS must be set to comma separated 'id:secret' link signing keys."
+# Optional comma separated ARNs of SNS topics delivering SES notifications to
+# the frontend's /sns endpoint over HTTPS, e.g. from SES in another region.
+NEWSLETTER_SNS_TOPIC_ARNS="${NEWSLETTER_SNS_TOPIC_ARNS:-}"
+
 REPO_DIR=$(git rev-parse --show-toplevel) || _error "Failed to find root of repo."
 pushd "$REPO_DIR" > /dev/null
 
@@ -61,7 +66,9 @@ aws cloudformation update-stack --stack-name "$STACK_NAME" \
     "ParameterKey=ArtifactBucket,ParameterValue=$S3_BUCKET" \
     "ParameterKey=CertificateARN,ParameterValue=$CERTIFICATE_ARN" \
     "ParameterKey=DomainName,ParameterValue=$DOMAIN_NAME" \
-    "ParameterKey=HostedZoneID,ParameterValue=$HOSTED_ZONE_ID" || 
+    "ParameterKey=HostedZoneID,ParameterValue=$HOSTED_ZONE_ID" \
+    "ParameterKey=SigningKeys,ParameterValue=${NEWSLETTER_SIGNING_KEYS//,/\\,}" \
+    "ParameterKey=SnsTopicArns,ParameterValue=${NEWSLETTER_SNS_TOPIC_ARNS//,/\\,}" || 
   _error "Failed to update cloudformation"
 echo "Waiting for stack update to complete..."
 aws cloudformation wait stack-update-complete --stack-name "$STACK_NAME"
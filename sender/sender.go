@@ -0,0 +1,52 @@
+package sender
+
+import (
+	"html/template"
+	"time"
+
+	"github.com/apex/log"
+	"gjhr.me/newsletter/data/mail"
+	"gjhr.me/newsletter/data/subscription"
+	"gjhr.me/newsletter/emailsender"
+	"gjhr.me/newsletter/providers/storage"
+)
+
+// Send renders a queued mail from its stored template and sends it.
+func Send(m mail.Mail, logger *log.Entry) error {
+	logger.Infof("Processing mail for '%v' with subject '%v'...", m.To, m.Subject)
+
+	// Download template
+	logger.Debugf("Downloading template from bucket '%v' key '%v'", m.TemplateBucket, m.TemplateKey)
+	body, err := storage.Templates().Get(m.TemplateKey)
+	if err != nil {
+		logger.WithError(err).Error("Error downloading template")
+		return err
+	}
+	logger.Debugf("Parsing template:\n%v", body)
+	t, err := template.New("body").Parse(body)
+	if err != nil {
+		logger.WithError(err).Error("Error parsing template")
+		return err
+	}
+
+	// Send mail
+	logger.Debugf("Sending mail to '%v'", m.To)
+	headers := append(emailsender.ListHeaders(m.ListID, m.TemplateValues.UnsubscribeLink), emailsender.TrackingHeaders(m.List, m.Issue)...)
+	err = emailsender.SendMail(m.To, m.From, m.ReplyTo, m.Subject, t, m.TemplateValues, headers...)
+	if err != nil {
+		logger.WithError(err).Error("Error sending mail")
+		return err
+	}
+
+	// Time opens from now rather than from when the mail was queued, as it
+	// may have waited in the queue
+	if m.TemplateValues.OpenPixel != "" {
+		subscriber := subscription.Subscription{List: m.List, Email: m.To}.Hash()
+		err = storage.Opens().RecordSent(m.Issue, subscriber, time.Now())
+		if err != nil {
+			// The mail is sent, failing would have it sent again
+			logger.WithError(err).Warn("Failed to record send time")
+		}
+	}
+	return nil
+}
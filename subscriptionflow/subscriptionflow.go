@@ -3,7 +3,6 @@ package listmanagement
 import (
 	"errors"
 	"fmt"
-	"html/template"
 	"net/mail"
 	"time"
 
@@ -11,7 +10,11 @@ import (
 	"github.com/google/uuid"
 	"gjhr.me/newsletter/data/list"
 	"gjhr.me/newsletter/data/subscription"
+	"gjhr.me/newsletter/data/suppression"
 	"gjhr.me/newsletter/emailsender"
+	"gjhr.me/newsletter/listtemplates"
+	"gjhr.me/newsletter/providers/storage"
+	"golang.org/x/exp/slices"
 )
 
 var ERR_UNEXPECTED = errors.New("An unexpected error has occurred.")
@@ -22,23 +25,61 @@ var ERR_INVALID_EMAIL = errors.New("Invalid email address.")
 var ERR_RECENTLY_SENT_VERIFICATION = errors.New("A verification email for this subscription has recently been sent.")
 var ERR_ALREADY_VERIFIED = errors.New("Subscription already verified.")
 var ERR_SUBSCRIPTION_NOT_FOUND = errors.New("Subscription does not exist.")
+var ERR_INVALID_UNSUBSCRIBE_LINK = errors.New("Invalid unsubscribe link.")
+var ERR_ADDRESS_SUPPRESSED = errors.New("Mail to this address has bounced or been reported as spam, it cannot be subscribed.")
+var ERR_INVALID_DELIVERY = errors.New("Invalid delivery, expected immediate, daily or weekly.")
+var ERR_INVALID_PREFERENCES_LINK = errors.New("Invalid preferences link.")
+var ERR_UNKNOWN_FEED = errors.New("Unknown feed.")
+var ERR_NO_FEEDS_CHOSEN = errors.New("Choose at least one feed, or unsubscribe.")
+var ERR_ALREADY_SUBSCRIBED = errors.New("This address is already subscribed.")
 
-func Subscribe(list *list.List, email string) (*subscription.Subscription, error) {
-	log.Infof("Subscribing '%v' to list '%v'...", email, list.Name)
+// How long to wait before emailing an address the same link again, so the
+// list cannot be used to flood it.
+const resendInterval = 15 * time.Minute
+
+// Subscribe subscribes an address to a list pending verification. Delivery is
+// how the subscriber wants new items sent, empty for the list's default, and
+// feeds the keys of the feeds they want, empty for all of them.
+func Subscribe(l *list.List, email string, delivery string, feeds []string) (*subscription.Subscription, error) {
+	log.Infof("Subscribing '%v' to list '%v'...", email, l.Name)
 	// Validate email
 	validAddress, err := mail.ParseAddress(email)
 	if err != nil {
 		return nil, ERR_INVALID_EMAIL
 	}
 	email = validAddress.Address
+	if delivery != "" && !list.IsValidDelivery(delivery) {
+		return nil, ERR_INVALID_DELIVERY
+	}
+	feeds, err = chosenFeeds(l, feeds)
+	if err != nil {
+		return nil, err
+	}
+
+	suppressed, err := suppression.IsSuppressed(storage.Suppressions(), email)
+	if err != nil {
+		return nil, err
+	}
+	if suppressed {
+		return nil, ERR_ADDRESS_SUPPRESSED
+	}
 
-	sub, err := subscription.Get(list.Name, email)
+	sub, err := storage.Subscriptions().Get(l.Name, email)
 	if err != nil && err != subscription.ERR_SUBSCRIPTION_NOT_FOUND {
 		return nil, err
 	}
 
 	if sub != nil {
-		return sub, resendVerificationEmail(*sub, list)
+		// Take the latest choices of a subscriber who has not verified yet
+		if sub.Verified == "" && (sub.Delivery != delivery || !slices.Equal(sub.Feeds, feeds)) {
+			sub.Delivery = delivery
+			sub.Feeds = feeds
+			err = storage.Subscriptions().Put(sub)
+			if err != nil {
+				return nil, err
+			}
+		}
+		return sub, resendVerificationEmail(*sub, l)
 	}
 
 	// Generate verification token
@@ -50,22 +91,25 @@ func Subscribe(list *list.List, email string) (*subscription.Subscription, error
 	// Save row to Dynamodb table
 	sub = &subscription.Subscription{
 		Email:                email,
-		List:                 list.Name,
+		List:                 l.Name,
 		VerificationToken:    uuid.String(),
 		LastSentVerification: time.Now(),
+		Delivery:             delivery,
+		Feeds:                feeds,
+		Subscribed:           time.Now(),
 	}
-	err = subscription.Put(sub)
+	err = storage.Subscriptions().Put(sub)
 	if err != nil {
 		return nil, err
 	}
 
 	// Send verification email
-	return sub, sendVerificationEmail(*sub, list)
+	return sub, sendVerificationEmail(*sub, l)
 }
 
 func resendVerificationEmail(sub subscription.Subscription, l *list.List) error {
 	log.Infof("Resending verification email to '%v' for list '%v'...", sub.Email, sub.List)
-	if sub.LastSentVerification.After(time.Now().Add(time.Minute * -15)) {
+	if sub.LastSentVerification.After(time.Now().Add(-resendInterval)) {
 		return ERR_RECENTLY_SENT_VERIFICATION
 	}
 	err := sendVerificationEmail(sub, l)
@@ -73,7 +117,7 @@ func resendVerificationEmail(sub subscription.Subscription, l *list.List) error
 		return err
 	}
 
-	return sub.UpdateLastSentVerification()
+	return storage.Subscriptions().UpdateLastSentVerification(&sub)
 }
 
 func sendVerificationEmail(sub subscription.Subscription, l *list.List) error {
@@ -82,34 +126,12 @@ func sendVerificationEmail(sub subscription.Subscription, l *list.List) error {
 		return ERR_ALREADY_VERIFIED
 	}
 
-	t, err := template.New("verification-email").Parse(`
-	<!DOCTYPE html>
-	<html lang="en" xmlns="http://www.w3.org/1999/xhtml" xmlns:o="urn:schemas-microsoft-com:office:office">
-	<head>
-			<meta charset="UTF-8">
-			<meta name="viewport" content="width=device-width,initial-scale=1">
-			<meta name="x-apple-disable-message-reformatting">
-			<title></title>
-			<style>
-					body {font-family: Arial, sans-serif;}
-			</style>
-	</head>
-	<body>
-		<h3>Please verify your email</h3>
-		<p>
-			To complete your subscription to {{ .List }}, please click <a href="{{ .VerificationLink }}">this link</a> or browse to the URL below.
-		</p>
-		<p>
-			{{ .VerificationLink }}
-		</p>
-	</body>
-	</html>
-	`)
+	t, err := listtemplates.Load(l)
 	if err != nil {
 		return err
 	}
 
-	err = emailsender.SendMail(sub.Email, l.FromAddress, l.ReplyToAddress, fmt.Sprintf("Verify email for %v", l.Name), t, struct{ List, VerificationLink string }{List: l.Name, VerificationLink: l.FormatVerificationLink(sub)})
+	err = emailsender.SendMail(sub.Email, l.FormatFromAddress(), l.ReplyToAddress, fmt.Sprintf("Verify email for %v", l.Name), t.Lookup(listtemplates.VERIFICATION_EMAIL), struct{ List, VerificationLink string }{List: l.Name, VerificationLink: l.FormatVerificationLink(sub)})
 	if err != nil {
 		return err
 	}
@@ -120,7 +142,7 @@ func sendVerificationEmail(sub subscription.Subscription, l *list.List) error {
 func Verify(token string) error {
 	log.Infof("Verifiying token '%v'...", token)
 	// Set email as verified
-	sub, err := subscription.GetFromToken(token)
+	sub, err := storage.Subscriptions().GetFromToken(token)
 	if err != nil {
 		return ERR_SUBSCRIPTION_NOT_FOUND
 	}
@@ -130,22 +152,202 @@ func Verify(token string) error {
 		return ERR_ALREADY_VERIFIED
 	}
 
-	err = sub.Verify()
+	err = storage.Subscriptions().Verify(sub)
 	if err != nil {
 		return err
 	}
 
+	if sub.ReplacesEmail != "" {
+		// The subscriber changed address, the old subscription is done with
+		log.Infof("Subscription to list '%v' moved to new address, removing the old one...", sub.List)
+		old, err := storage.Subscriptions().Get(sub.List, sub.ReplacesEmail)
+		if err == subscription.ERR_SUBSCRIPTION_NOT_FOUND {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return storage.Subscriptions().Delete(old)
+	}
+
 	return nil
 }
 
-func Unsubscribe(list, email string) error {
-	// Delete row from table
-	log.Infof("Removing subscription of email '%v' to list '%v'...", email, list)
+// Preferences returns the subscription identified by a token from a link
+// formatted by List.FormatPreferencesLink.
+func Preferences(l *list.List, token string) (*subscription.Subscription, error) {
+	link, err := list.ParseSubscriptionLink(list.TOKEN_PURPOSE_PREFERENCES, token)
+	if err != nil {
+		return nil, ERR_INVALID_PREFERENCES_LINK
+	}
+	return linkedSubscription(l, link)
+}
+
+// linkedSubscription gets the subscription to the list a link was sent for.
+func linkedSubscription(l *list.List, link *list.SubscriptionLink) (*subscription.Subscription, error) {
+	sub, err := storage.Subscriptions().Get(l.Name, link.Email)
+	if err != nil || !link.IsFor(sub) {
+		return nil, ERR_SUBSCRIPTION_NOT_FOUND
+	}
+	return sub, nil
+}
 
-	sub, err := subscription.Get(list, email)
+// UpdatePreferences validates and saves the delivery, pause and feeds of a
+// subscription.
+func UpdatePreferences(l *list.List, sub *subscription.Subscription) error {
+	if sub.Delivery != "" && !list.IsValidDelivery(sub.Delivery) {
+		return ERR_INVALID_DELIVERY
+	}
+	feeds, err := chosenFeeds(l, sub.Feeds)
 	if err != nil {
-		return ERR_SUBSCRIPTION_NOT_FOUND
+		return err
+	}
+	sub.Feeds = feeds
+	if !sub.IsPaused(time.Now()) {
+		sub.PausedUntil = time.Time{}
 	}
+	log.Infof("Updating preferences of subscription to list '%v'...", l.Name)
+	err = storage.Subscriptions().UpdatePreferences(sub)
+	if err != nil {
+		return err
+	}
+	// The subscriber followed a link from their mail, so it reaches them again
+	if sub.Suspended {
+		log.Infof("Reactivating suspended subscription to list '%v'...", l.Name)
+		return storage.Subscriptions().Reactivate(sub)
+	}
+	return nil
+}
+
+// chosenFeeds checks a choice of feeds by key. Choosing every feed is saved as
+// no choice, so feeds added to the list later are sent too.
+func chosenFeeds(l *list.List, feeds []string) ([]string, error) {
+	feeds = append([]string(nil), feeds...)
+	slices.Sort(feeds)
+	feeds = slices.Compact(feeds)
+	for _, key := range feeds {
+		if !l.HasFeed(key) {
+			return nil, ERR_UNKNOWN_FEED
+		}
+	}
+	if len(feeds) == 0 || len(feeds) == len(l.Feeds) {
+		return nil, nil
+	}
+	return feeds, nil
+}
 
-	return sub.Delete()
+// ChangeEmail subscribes a new address in place of a subscription's, with
+// the same preferences. The old subscription stays until the new address is
+// verified.
+func ChangeEmail(l *list.List, sub *subscription.Subscription, email string) (*subscription.Subscription, error) {
+	validAddress, err := mail.ParseAddress(email)
+	if err != nil {
+		return nil, ERR_INVALID_EMAIL
+	}
+	email = validAddress.Address
+	if email == sub.Email {
+		return sub, nil
+	}
+	log.Infof("Changing address of subscription to list '%v'...", l.Name)
+
+	suppressed, err := suppression.IsSuppressed(storage.Suppressions(), email)
+	if err != nil {
+		return nil, err
+	}
+	if suppressed {
+		return nil, ERR_ADDRESS_SUPPRESSED
+	}
+
+	existing, err := storage.Subscriptions().Get(l.Name, email)
+	if err != nil && err != subscription.ERR_SUBSCRIPTION_NOT_FOUND {
+		return nil, err
+	}
+	if existing != nil {
+		if existing.ReplacesEmail != sub.Email {
+			return nil, ERR_ALREADY_SUBSCRIBED
+		}
+		return existing, resendVerificationEmail(*existing, l)
+	}
+
+	uuid, err := uuid.NewUUID()
+	if err != nil {
+		return nil, err
+	}
+	moved := &subscription.Subscription{
+		Email:                email,
+		List:                 l.Name,
+		VerificationToken:    uuid.String(),
+		LastSentVerification: time.Now(),
+		Delivery:             sub.Delivery,
+		Subscribed:           sub.Subscribed,
+		PausedUntil:          sub.PausedUntil,
+		Feeds:                sub.Feeds,
+		Filter:               sub.Filter.Copy(),
+		ReplacesEmail:        sub.Email,
+	}
+	err = storage.Subscriptions().Put(moved)
+	if err != nil {
+		return nil, err
+	}
+	return moved, sendVerificationEmail(*moved, l)
+}
+
+// RequestUnsubscribe emails a signed unsubscribe link to a subscriber, at most
+// once every resendInterval. Unknown addresses and repeated requests are
+// silently ignored so the response does not reveal who is subscribed.
+func RequestUnsubscribe(l *list.List, email string) error {
+	validAddress, err := mail.ParseAddress(email)
+	if err != nil {
+		return ERR_INVALID_EMAIL
+	}
+	sub, err := storage.Subscriptions().Get(l.Name, validAddress.Address)
+	if err == subscription.ERR_SUBSCRIPTION_NOT_FOUND {
+		log.Infof("Unsubscribe link requested for unknown subscription to list '%v'", l.Name)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	suppressed, err := suppression.IsSuppressed(storage.Suppressions(), sub.Email)
+	if err != nil {
+		return err
+	}
+	if suppressed {
+		log.Infof("Unsubscribe link requested for suppressed address on list '%v'", l.Name)
+		return nil
+	}
+	if sub.LastSentUnsubscribe.After(time.Now().Add(-resendInterval)) {
+		log.Infof("Unsubscribe link recently sent for list '%v', ignoring request", l.Name)
+		return nil
+	}
+	log.Infof("Sending unsubscribe link for list '%v'...", l.Name)
+
+	t, err := listtemplates.Load(l)
+	if err != nil {
+		return err
+	}
+
+	err = emailsender.SendMail(sub.Email, l.FormatFromAddress(), l.ReplyToAddress, fmt.Sprintf("Unsubscribe from %v", l.Name), t.Lookup(listtemplates.UNSUBSCRIBE_EMAIL), struct{ List, UnsubscribeLink string }{List: l.Name, UnsubscribeLink: l.FormatUnsubscribeLink(*sub)})
+	if err != nil {
+		return err
+	}
+	return storage.Subscriptions().UpdateLastSentUnsubscribe(sub)
+}
+
+// Unsubscribe deletes the subscription identified by a token from a link
+// formatted by List.FormatUnsubscribeLink.
+func Unsubscribe(l *list.List, token string) error {
+	link, err := list.ParseSubscriptionLink(list.TOKEN_PURPOSE_UNSUBSCRIBE, token)
+	if err != nil {
+		return ERR_INVALID_UNSUBSCRIBE_LINK
+	}
+
+	sub, err := linkedSubscription(l, link)
+	if err != nil {
+		return err
+	}
+
+	// Delete row from table
+	log.Infof("Removing subscription to list '%v'...", l.Name)
+	return storage.Subscriptions().Delete(sub)
 }
@@ -0,0 +1,173 @@
+package listmanagement
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"gjhr.me/newsletter/data/list"
+	"gjhr.me/newsletter/data/subscription"
+	"gjhr.me/newsletter/emailsender"
+	"gjhr.me/newsletter/itemfilter"
+	"gjhr.me/newsletter/providers/signing"
+	"gjhr.me/newsletter/providers/storage"
+)
+
+// recordingTransport keeps the messages it is asked to send.
+type recordingTransport struct {
+	sent []*emailsender.Message
+}
+
+func (t *recordingTransport) Send(msg *emailsender.Message) error {
+	t.sent = append(t.sent, msg)
+	return nil
+}
+
+func setUp(t *testing.T) (*list.List, *recordingTransport) {
+	t.Helper()
+	err := signing.UseEphemeralKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.Use("memory", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := &recordingTransport{}
+	emailsender.SetTransport(transport)
+	l := &list.List{Name: "list", Domain: "list.example.com", FromAddress: "list@example.com"}
+	err = storage.Lists().Put(l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l, transport
+}
+
+func TestRequestUnsubscribeIsRateLimited(t *testing.T) {
+	l, transport := setUp(t)
+	err := storage.Subscriptions().Put(&subscription.Subscription{Email: "reader@example.com", List: l.Name, Verified: "yes", VerificationToken: "token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		err = RequestUnsubscribe(l, "reader@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(transport.sent) != 1 {
+		t.Errorf("sent %v unsubscribe mails, want 1", len(transport.sent))
+	}
+
+	// Once the interval has passed another link can be requested
+	sub, _ := storage.Subscriptions().Get(l.Name, "reader@example.com")
+	sub.LastSentUnsubscribe = time.Now().Add(-resendInterval - time.Minute)
+	err = storage.Subscriptions().Put(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = RequestUnsubscribe(l, "reader@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transport.sent) != 2 {
+		t.Errorf("sent %v unsubscribe mails after the interval, want 2", len(transport.sent))
+	}
+}
+
+func TestRequestUnsubscribeIgnoresUnknownAddresses(t *testing.T) {
+	l, transport := setUp(t)
+	err := RequestUnsubscribe(l, "stranger@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(transport.sent) != 0 {
+		t.Errorf("sent %v mails to an unknown address", len(transport.sent))
+	}
+}
+
+func TestChangeEmailKeepsPreferences(t *testing.T) {
+	l, _ := setUp(t)
+	l.Feeds = []list.Feed{{Url: "https://example.com/a.xml", Slug: "a"}, {Url: "https://example.com/b.xml", Slug: "b"}}
+	filter := &itemfilter.Filter{Exclude: []itemfilter.Rule{{Categories: []string{"beta"}}}}
+	old := &subscription.Subscription{
+		Email:             "old@example.com",
+		List:              l.Name,
+		Verified:          "yes",
+		VerificationToken: "token",
+		Delivery:          list.DELIVERY_WEEKLY,
+		Feeds:             []string{"a"},
+		Filter:            filter,
+	}
+	err := storage.Subscriptions().Put(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	moved, err := ChangeEmail(l, old, "new@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.Delivery != old.Delivery || len(moved.Feeds) != 1 || moved.Feeds[0] != "a" {
+		t.Errorf("delivery and feeds not kept: %+v", moved)
+	}
+	if moved.Filter == nil || len(moved.Filter.Exclude) != 1 || moved.Filter.Exclude[0].Categories[0] != "beta" {
+		t.Fatalf("filter not kept: %+v", moved.Filter)
+	}
+	filter.Exclude[0].Categories[0] = "changed"
+	if moved.Filter.Exclude[0].Categories[0] != "beta" {
+		t.Error("filter shared with the old subscription")
+	}
+}
+
+func TestSubscriptionLinksDoNotCarryVerificationToken(t *testing.T) {
+	l, _ := setUp(t)
+	sub := &subscription.Subscription{Email: "reader@example.com", List: l.Name, Verified: "yes", VerificationToken: "secret-token"}
+	err := storage.Subscriptions().Put(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link, err := url.Parse(l.FormatUnsubscribeLink(*sub))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := link.Query().Get("token")
+	payload, err := signing.Signer().Verify(list.TOKEN_PURPOSE_UNSUBSCRIBE, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(payload, sub.VerificationToken) {
+		t.Errorf("link payload %q carries the verification token", payload)
+	}
+	_, err = Preferences(l, token)
+	if err != ERR_INVALID_PREFERENCES_LINK {
+		t.Errorf("got error %v for an unsubscribe token used as a preferences one, want %v", err, ERR_INVALID_PREFERENCES_LINK)
+	}
+
+	// Links sent for an earlier subscription of the address stop working
+	sub.VerificationToken = "new-token"
+	err = storage.Subscriptions().Put(sub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Unsubscribe(l, token)
+	if err != ERR_SUBSCRIPTION_NOT_FOUND {
+		t.Errorf("got error %v for a stale link, want %v", err, ERR_SUBSCRIPTION_NOT_FOUND)
+	}
+
+	link, err = url.Parse(l.FormatUnsubscribeLink(*sub))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Unsubscribe(l, link.Query().Get("token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = storage.Subscriptions().Get(l.Name, sub.Email)
+	if err == nil {
+		t.Error("subscription not deleted")
+	}
+}
@@ -0,0 +1,112 @@
+package tracking
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/apex/log"
+	"gjhr.me/newsletter/data/list"
+	"gjhr.me/newsletter/data/opens"
+	"gjhr.me/newsletter/data/subscription"
+	"gjhr.me/newsletter/providers/signing"
+)
+
+// Purposes signed into tracking tokens.
+const (
+	TOKEN_PURPOSE_CLICK = "click"
+	TOKEN_PURPOSE_OPEN  = "open"
+)
+
+// Opens this soon after an issue was sent are assumed to be made by a
+// scanner or prefetching client rather than a reader.
+const QUICK_OPEN_WINDOW = 10 * time.Second
+
+// Click identifies a tracked link in the issue sent to one subscriber.
+type Click struct {
+	Issue      string `json:"i"`
+	Link       string `json:"l"`
+	Subscriber string `json:"s"`
+}
+
+// ClickLinks formats a signed redirect link for each of the links of an issue
+// as sent to the subscription. The destination is in the token so the
+// redirect cannot be pointed anywhere else.
+func ClickLinks(l *list.List, issue string, links []string, sub subscription.Subscription) []string {
+	result := make([]string, 0, len(links))
+	for _, link := range links {
+		payload, _ := json.Marshal(Click{Issue: issue, Link: link, Subscriber: sub.Hash()})
+		token := signing.Signer().Sign(TOKEN_PURPOSE_CLICK, string(payload))
+		result = append(result, fmt.Sprintf("%v/r/%v", l.FormatBaseURL(), token))
+	}
+	return result
+}
+
+// ParseClick verifies a click tracking token.
+func ParseClick(token string) (*Click, error) {
+	payload, err := signing.Signer().Verify(TOKEN_PURPOSE_CLICK, token)
+	if err != nil {
+		return nil, err
+	}
+	var click Click
+	err = json.Unmarshal([]byte(payload), &click)
+	if err != nil {
+		return nil, err
+	}
+	return &click, nil
+}
+
+// Open identifies the issue sent to one subscriber, for its tracking pixel.
+type Open struct {
+	Issue      string `json:"i"`
+	Subscriber string `json:"s"`
+	// Unix time the mail was queued at, for when its send was not recorded
+	Sent int64 `json:"t"`
+}
+
+// OpenPixel formats the signed link of the tracking pixel of an issue as sent
+// to the subscription.
+func OpenPixel(l *list.List, issue string, sub subscription.Subscription, sent time.Time) string {
+	payload, _ := json.Marshal(Open{Issue: issue, Subscriber: sub.Hash(), Sent: sent.Unix()})
+	token := signing.Signer().Sign(TOKEN_PURPOSE_OPEN, string(payload))
+	return fmt.Sprintf("%v/o/%v.gif", l.FormatBaseURL(), token)
+}
+
+// ParseOpen verifies a tracking pixel token, with or without its extension.
+func ParseOpen(token string) (*Open, error) {
+	payload, err := signing.Signer().Verify(TOKEN_PURPOSE_OPEN, strings.TrimSuffix(token, ".gif"))
+	if err != nil {
+		return nil, err
+	}
+	var open Open
+	err = json.Unmarshal([]byte(payload), &open)
+	if err != nil {
+		return nil, err
+	}
+	return &open, nil
+}
+
+// IsMachineOpen reports whether an open was likely made by Apple Mail Privacy
+// Protection, which fetches images through proxies identifying only as
+// "Mozilla/5.0", or by a scanner fetching the pixel right after the mail was
+// sent.
+func IsMachineOpen(userAgent string, sent time.Time, at time.Time) bool {
+	if strings.TrimSpace(userAgent) == "Mozilla/5.0" {
+		return true
+	}
+	return at.Sub(sent) < QUICK_OPEN_WINDOW
+}
+
+// SentAt returns when the mail an open is of was sent, as recorded by the
+// sender, or failing that when it was queued.
+func SentAt(store opens.OpenStore, open *Open) time.Time {
+	recorded, err := store.Get(open.Issue, open.Subscriber)
+	if err == nil && !recorded.Sent.IsZero() {
+		return recorded.Sent
+	}
+	if err != nil && err != opens.ERR_OPENS_NOT_FOUND {
+		log.WithError(err).WithField("issue", open.Issue).Warn("Failed to get send time, using queue time")
+	}
+	return time.Unix(open.Sent, 0)
+}
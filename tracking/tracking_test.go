@@ -0,0 +1,52 @@
+package tracking
+
+import (
+	"testing"
+	"time"
+
+	"gjhr.me/newsletter/data/opens"
+)
+
+func TestIsMachineOpen(t *testing.T) {
+	sent := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		userAgent string
+		at        time.Time
+		want      bool
+	}{
+		{"privacy proxy", "Mozilla/5.0", sent.Add(time.Hour), true},
+		{"prefetch right after sending", "Mozilla/5.0 (Macintosh) AppleWebKit", sent.Add(2 * time.Second), true},
+		{"reader", "Mozilla/5.0 (Macintosh) AppleWebKit", sent.Add(time.Minute), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := IsMachineOpen(test.userAgent, sent, test.at)
+			if got != test.want {
+				t.Errorf("IsMachineOpen() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSentAtPrefersRecordedSendTime(t *testing.T) {
+	queued := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	sent := queued.Add(3 * time.Minute)
+	open := &Open{Issue: "issue", Subscriber: "subscriber", Sent: queued.Unix()}
+
+	store := opens.NewMemoryOpenStore()
+	if got := SentAt(store, open); !got.Equal(queued) {
+		t.Errorf("SentAt() without a recorded send = %v, want the queue time %v", got, queued)
+	}
+	err := store.RecordSent("issue", "subscriber", sent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := SentAt(store, open); !got.Equal(sent) {
+		t.Errorf("SentAt() = %v, want the recorded send time %v", got, sent)
+	}
+	// An open seconds after sending is a prefetch, even minutes after queueing
+	if !IsMachineOpen("Mozilla/5.0 (Macintosh)", SentAt(store, open), sent.Add(time.Second)) {
+		t.Error("open right after sending not flagged as a machine open")
+	}
+}
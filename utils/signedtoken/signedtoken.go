@@ -0,0 +1,101 @@
+package signedtoken
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gjhr.me/newsletter/utils/consterror"
+)
+
+const (
+	ERR_INVALID_TOKEN = consterror.ConstError("Invalid or tampered token")
+	ERR_NO_KEYS       = consterror.ConstError("No signing keys configured")
+)
+
+var keyIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Signer produces URL safe tokens of the form <payload>.<key id>.<hmac>.
+// Tokens are signed with the first (active) key and verified with whichever
+// key they name, so old keys can be kept around while links signed with them
+// are still in inboxes.
+type Signer struct {
+	keys   map[string][]byte
+	active string
+}
+
+// NewSigner parses keys given as "id:secret,id:secret". The first key signs
+// new tokens, all keys verify.
+func NewSigner(keys string) (*Signer, error) {
+	s := &Signer{keys: map[string][]byte{}}
+	for _, pair := range strings.Split(keys, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		id, secret, found := strings.Cut(pair, ":")
+		if !found || !keyIDPattern.MatchString(id) || secret == "" {
+			return nil, fmt.Errorf("Malformed signing key '%v', expected 'id:secret'", id)
+		}
+		if _, exists := s.keys[id]; exists {
+			return nil, fmt.Errorf("Duplicate signing key id '%v'", id)
+		}
+		s.keys[id] = []byte(secret)
+		if s.active == "" {
+			s.active = id
+		}
+	}
+	if s.active == "" {
+		return nil, ERR_NO_KEYS
+	}
+	return s, nil
+}
+
+// Sign binds payload to purpose so a token issued for one use cannot be
+// replayed for another.
+func (s *Signer) Sign(purpose string, payload string) string {
+	return strings.Join([]string{
+		encode([]byte(payload)),
+		s.active,
+		encode(s.mac(s.keys[s.active], purpose, payload)),
+	}, ".")
+}
+
+// Verify returns the payload of a token signed for purpose by any known key.
+func (s *Signer) Verify(purpose string, token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", ERR_INVALID_TOKEN
+	}
+	key, ok := s.keys[parts[1]]
+	if !ok {
+		return "", ERR_INVALID_TOKEN
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", ERR_INVALID_TOKEN
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", ERR_INVALID_TOKEN
+	}
+	if !hmac.Equal(mac, s.mac(key, purpose, string(payload))) {
+		return "", ERR_INVALID_TOKEN
+	}
+	return string(payload), nil
+}
+
+func (s *Signer) mac(key []byte, purpose string, payload string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(purpose))
+	h.Write([]byte{0})
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
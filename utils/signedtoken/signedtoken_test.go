@@ -0,0 +1,105 @@
+package signedtoken
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewSigner(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    string
+		active  string
+		wantErr bool
+	}{
+		{"one key", "k1:secret", "k1", false},
+		{"first key is active", "k2:new, k1:old", "k2", false},
+		{"skips empty entries", ",k1:secret,", "k1", false},
+		{"secret may contain colons", "k1:se:cret", "k1", false},
+		{"no keys", "", "", true},
+		{"only separators", " , ", "", true},
+		{"missing secret", "k1:", "", true},
+		{"missing colon", "k1", "", true},
+		{"invalid id", "k.1:secret", "", true},
+		{"duplicate id", "k1:a,k1:b", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, err := NewSigner(test.keys)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("expected an error for %q", test.keys)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.active != test.active {
+				t.Errorf("got active key %q, want %q", s.active, test.active)
+			}
+		})
+	}
+}
+
+func newSigner(t *testing.T, keys string) *Signer {
+	t.Helper()
+	s, err := NewSigner(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSignAndVerify(t *testing.T) {
+	old := newSigner(t, "k1:old")
+	rotated := newSigner(t, "k2:new,k1:old")
+	retired := newSigner(t, "k2:new")
+	token := old.Sign("unsubscribe", "payload.with.dots")
+	parts := strings.Split(token, ".")
+
+	tests := []struct {
+		name    string
+		signer  *Signer
+		purpose string
+		token   string
+		wantErr bool
+	}{
+		{"same signer", old, "unsubscribe", token, false},
+		{"old key after rotation", rotated, "unsubscribe", token, false},
+		{"new key after rotation", rotated, "unsubscribe", rotated.Sign("unsubscribe", "payload.with.dots"), false},
+		{"retired key", retired, "unsubscribe", token, true},
+		{"other purpose", old, "preferences", token, true},
+		{"same key id, other secret", newSigner(t, "k1:other"), "unsubscribe", token, true},
+		{"tampered payload", old, "unsubscribe", encode([]byte("other")) + "." + parts[1] + "." + parts[2], true},
+		{"tampered mac", old, "unsubscribe", parts[0] + "." + parts[1] + "." + encode([]byte("mac")), true},
+		{"renamed key", rotated, "unsubscribe", parts[0] + ".k2." + parts[2], true},
+		{"too few parts", old, "unsubscribe", parts[0] + "." + parts[2], true},
+		{"malformed payload", old, "unsubscribe", "!!." + parts[1] + "." + parts[2], true},
+		{"empty", old, "unsubscribe", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			payload, err := test.signer.Verify(test.purpose, test.token)
+			if test.wantErr {
+				if err != ERR_INVALID_TOKEN {
+					t.Errorf("got error %v, want %v", err, ERR_INVALID_TOKEN)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if payload != "payload.with.dots" {
+				t.Errorf("got payload %q", payload)
+			}
+		})
+	}
+}
+
+func TestSignIsURLSafe(t *testing.T) {
+	token := newSigner(t, "k1:secret").Sign("unsubscribe", "a+b/c=d?e&f")
+	if strings.ContainsAny(token, "+/=?&") {
+		t.Errorf("token %q is not URL safe", token)
+	}
+}
@@ -0,0 +1,220 @@
+package snsmessage
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"gjhr.me/newsletter/utils/consterror"
+)
+
+const (
+	ERR_UNSUPPORTED_SIGNATURE_VERSION = consterror.ConstError("Unsupported SNS signature version")
+	ERR_UNTRUSTED_URL                 = consterror.ConstError("URL is not an SNS endpoint")
+	ERR_INVALID_CERTIFICATE           = consterror.ConstError("Invalid SNS signing certificate")
+	ERR_INVALID_SIGNATURE             = consterror.ConstError("Invalid SNS message signature")
+)
+
+// Message types.
+const (
+	TYPE_NOTIFICATION              = "Notification"
+	TYPE_SUBSCRIPTION_CONFIRMATION = "SubscriptionConfirmation"
+	TYPE_UNSUBSCRIBE_CONFIRMATION  = "UnsubscribeConfirmation"
+)
+
+// Message is an SNS message as posted to HTTPS endpoints.
+type Message struct {
+	Type             string `json:"Type"`
+	MessageId        string `json:"MessageId"`
+	Token            string `json:"Token,omitempty"`
+	TopicArn         string `json:"TopicArn"`
+	Subject          string `json:"Subject,omitempty"`
+	Message          string `json:"Message"`
+	Timestamp        string `json:"Timestamp"`
+	SignatureVersion string `json:"SignatureVersion"`
+	Signature        string `json:"Signature"`
+	SigningCertURL   string `json:"SigningCertURL"`
+	SubscribeURL     string `json:"SubscribeURL,omitempty"`
+	UnsubscribeURL   string `json:"UnsubscribeURL,omitempty"`
+}
+
+// FromLambda converts the SNS record of a Lambda event. The timestamp is
+// formatted back the way SNS signed it.
+func FromLambda(e events.SNSEntity) *Message {
+	return &Message{
+		Type:             e.Type,
+		MessageId:        e.MessageID,
+		TopicArn:         e.TopicArn,
+		Subject:          e.Subject,
+		Message:          e.Message,
+		Timestamp:        e.Timestamp.UTC().Format("2006-01-02T15:04:05.000Z"),
+		SignatureVersion: e.SignatureVersion,
+		Signature:        e.Signature,
+		SigningCertURL:   e.SigningCertURL,
+		UnsubscribeURL:   e.UnsubscribeURL,
+	}
+}
+
+// Hosts SNS serves certificates and subscription endpoints from.
+var snsHost = regexp.MustCompile(`^sns\.[a-z0-9-]+\.amazonaws\.com(\.cn)?$`)
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// Signing certificates by URL, they are only rotated with a new URL. The lock
+// is not held while fetching, so a slow fetch does not hold up others.
+var certificates = map[string]*x509.Certificate{}
+var certificatesMu sync.Mutex
+
+// Verify checks the message was signed by SNS.
+func (m *Message) Verify() error {
+	var hash crypto.Hash
+	switch m.SignatureVersion {
+	case "1":
+		hash = crypto.SHA1
+	case "2":
+		hash = crypto.SHA256
+	default:
+		return ERR_UNSUPPORTED_SIGNATURE_VERSION
+	}
+	cert, err := certificate(m.SigningCertURL)
+	if err != nil {
+		return err
+	}
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return ERR_INVALID_CERTIFICATE
+	}
+	signature, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil {
+		return ERR_INVALID_SIGNATURE
+	}
+	var digest []byte
+	if hash == crypto.SHA1 {
+		sum := sha1.Sum([]byte(m.stringToSign()))
+		digest = sum[:]
+	} else {
+		sum := sha256.Sum256([]byte(m.stringToSign()))
+		digest = sum[:]
+	}
+	if rsa.VerifyPKCS1v15(key, hash, digest, signature) != nil {
+		return ERR_INVALID_SIGNATURE
+	}
+	return nil
+}
+
+// ConfirmSubscription visits the subscribe URL of a SubscriptionConfirmation.
+func (m *Message) ConfirmSubscription() error {
+	if _, err := trustedURL(m.SubscribeURL); err != nil {
+		return err
+	}
+	res, err := client.Get(m.SubscribeURL)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("Confirming SNS subscription failed with status %v", res.Status)
+	}
+	return nil
+}
+
+// stringToSign lists the signed fields of the message type in order.
+func (m *Message) stringToSign() string {
+	fields := [][2]string{{"Message", m.Message}, {"MessageId", m.MessageId}}
+	if m.Type == TYPE_NOTIFICATION {
+		if m.Subject != "" {
+			fields = append(fields, [2]string{"Subject", m.Subject})
+		}
+	} else {
+		fields = append(fields, [2]string{"SubscribeURL", m.SubscribeURL})
+	}
+	fields = append(fields, [2]string{"Timestamp", m.Timestamp})
+	if m.Type != TYPE_NOTIFICATION {
+		fields = append(fields, [2]string{"Token", m.Token})
+	}
+	fields = append(fields, [2]string{"TopicArn", m.TopicArn}, [2]string{"Type", m.Type})
+
+	b := &strings.Builder{}
+	for _, field := range fields {
+		b.WriteString(field[0] + "\n" + field[1] + "\n")
+	}
+	return b.String()
+}
+
+func certificate(certURL string) (*x509.Certificate, error) {
+	certificatesMu.Lock()
+	cert, ok := certificates[certURL]
+	if ok && time.Now().After(cert.NotAfter) {
+		delete(certificates, certURL)
+		ok = false
+	}
+	certificatesMu.Unlock()
+	if ok {
+		return cert, nil
+	}
+
+	cert, err := fetchCertificate(certURL)
+	if err != nil {
+		return nil, err
+	}
+	certificatesMu.Lock()
+	certificates[certURL] = cert
+	certificatesMu.Unlock()
+	return cert, nil
+}
+
+func fetchCertificate(certURL string) (*x509.Certificate, error) {
+	u, err := trustedURL(certURL)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(u.Path, ".pem") {
+		return nil, ERR_UNTRUSTED_URL
+	}
+	res, err := client.Get(certURL)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Fetching SNS signing certificate failed with status %v", res.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, 64*1024))
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(body)
+	if block == nil {
+		return nil, ERR_INVALID_CERTIFICATE
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, ERR_INVALID_CERTIFICATE
+	}
+	if time.Now().After(cert.NotAfter) {
+		return nil, ERR_INVALID_CERTIFICATE
+	}
+	return cert, nil
+}
+
+// trustedURL parses a URL, accepting only HTTPS URLs of SNS itself.
+func trustedURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme != "https" || !snsHost.MatchString(u.Hostname()) || u.Port() != "" {
+		return nil, ERR_UNTRUSTED_URL
+	}
+	return u, nil
+}
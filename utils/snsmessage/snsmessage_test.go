@@ -0,0 +1,298 @@
+package snsmessage
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const testCertURL = "https://sns.eu-west-1.amazonaws.com/SimpleNotificationService-test.pem"
+
+// newCertificate creates a self-signed certificate valid until notAfter.
+func newCertificate(t *testing.T, notAfter time.Time) (*x509.Certificate, *rsa.PrivateKey) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sns.amazonaws.com"},
+		NotBefore:    time.Now().Add(-2 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+// seedCertificate caches a self-signed certificate for testCertURL, so no
+// certificate is fetched, and returns its key.
+func seedCertificate(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	cert, key := newCertificate(t, time.Now().Add(time.Hour))
+	certificatesMu.Lock()
+	certificates[testCertURL] = cert
+	certificatesMu.Unlock()
+	return key
+}
+
+// serveCertificate makes the client serve the certificate for any URL, calling
+// fetched before each response.
+func serveCertificate(t *testing.T, cert *x509.Certificate, fetched func()) {
+	t.Helper()
+	body := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	previous := client
+	client = &http.Client{Transport: roundTripper(func(req *http.Request) (*http.Response, error) {
+		fetched()
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(body)), Request: req}, nil
+	})}
+	t.Cleanup(func() { client = previous })
+}
+
+type roundTripper func(req *http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func sign(t *testing.T, key *rsa.PrivateKey, m *Message) {
+	t.Helper()
+	var digest []byte
+	hash := crypto.SHA256
+	if m.SignatureVersion == "1" {
+		hash = crypto.SHA1
+		sum := sha1.Sum([]byte(m.stringToSign()))
+		digest = sum[:]
+	} else {
+		sum := sha256.Sum256([]byte(m.stringToSign()))
+		digest = sum[:]
+	}
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, hash, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Signature = base64.StdEncoding.EncodeToString(signature)
+}
+
+func notification(version string) *Message {
+	return &Message{
+		Type:             TYPE_NOTIFICATION,
+		MessageId:        "id",
+		TopicArn:         "arn:aws:sns:eu-west-1:123456789012:bounces",
+		Subject:          "Bounce",
+		Message:          `{"notificationType":"Bounce"}`,
+		Timestamp:        "2024-01-02T03:04:05.678Z",
+		SignatureVersion: version,
+		SigningCertURL:   testCertURL,
+	}
+}
+
+func TestVerify(t *testing.T) {
+	key := seedCertificate(t)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		// Prepares a signed message
+		message func() *Message
+		want    error
+	}{
+		{"version 1", func() *Message {
+			m := notification("1")
+			sign(t, key, m)
+			return m
+		}, nil},
+		{"version 2", func() *Message {
+			m := notification("2")
+			sign(t, key, m)
+			return m
+		}, nil},
+		{"without subject", func() *Message {
+			m := notification("2")
+			m.Subject = ""
+			sign(t, key, m)
+			return m
+		}, nil},
+		{"subscription confirmation", func() *Message {
+			m := notification("2")
+			m.Type = TYPE_SUBSCRIPTION_CONFIRMATION
+			m.Subject = ""
+			m.Token = "token"
+			m.SubscribeURL = "https://sns.eu-west-1.amazonaws.com/?Action=ConfirmSubscription"
+			sign(t, key, m)
+			return m
+		}, nil},
+		{"tampered message", func() *Message {
+			m := notification("2")
+			sign(t, key, m)
+			m.Message = `{"notificationType":"Complaint"}`
+			return m
+		}, ERR_INVALID_SIGNATURE},
+		{"tampered topic", func() *Message {
+			m := notification("2")
+			sign(t, key, m)
+			m.TopicArn = "arn:aws:sns:eu-west-1:123456789012:other"
+			return m
+		}, ERR_INVALID_SIGNATURE},
+		{"tampered subscribe URL", func() *Message {
+			m := notification("2")
+			m.Type = TYPE_SUBSCRIPTION_CONFIRMATION
+			m.SubscribeURL = "https://sns.eu-west-1.amazonaws.com/?Action=ConfirmSubscription"
+			sign(t, key, m)
+			m.SubscribeURL = "https://sns.eu-west-1.amazonaws.com/?Action=Other"
+			return m
+		}, ERR_INVALID_SIGNATURE},
+		{"signed with another key", func() *Message {
+			m := notification("2")
+			sign(t, other, m)
+			return m
+		}, ERR_INVALID_SIGNATURE},
+		{"signed as another version", func() *Message {
+			m := notification("1")
+			sign(t, key, m)
+			m.SignatureVersion = "2"
+			return m
+		}, ERR_INVALID_SIGNATURE},
+		{"malformed signature", func() *Message {
+			m := notification("2")
+			m.Signature = "not base64!"
+			return m
+		}, ERR_INVALID_SIGNATURE},
+		{"unsupported version", func() *Message {
+			m := notification("3")
+			sign(t, key, m)
+			return m
+		}, ERR_UNSUPPORTED_SIGNATURE_VERSION},
+		{"untrusted certificate host", func() *Message {
+			m := notification("2")
+			m.SigningCertURL = "https://attacker.example.com/cert.pem"
+			sign(t, key, m)
+			return m
+		}, ERR_UNTRUSTED_URL},
+		{"certificate not a pem", func() *Message {
+			m := notification("2")
+			m.SigningCertURL = "https://sns.eu-west-1.amazonaws.com/cert.txt"
+			sign(t, key, m)
+			return m
+		}, ERR_UNTRUSTED_URL},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.message().Verify()
+			if err != test.want {
+				t.Errorf("got error %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestTrustedURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		trusted bool
+	}{
+		{"https://sns.us-east-1.amazonaws.com/cert.pem", true},
+		{"https://sns.cn-north-1.amazonaws.com.cn/cert.pem", true},
+		{"http://sns.us-east-1.amazonaws.com/cert.pem", false},
+		{"https://sns.us-east-1.amazonaws.com:8443/cert.pem", false},
+		{"https://sns.us-east-1.amazonaws.com.attacker.example/cert.pem", false},
+		{"https://attacker.example/sns.us-east-1.amazonaws.com/cert.pem", false},
+		{"https://s3.amazonaws.com/cert.pem", false},
+		{"https://user@sns.us-east-1.amazonaws.com@attacker.example/cert.pem", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		_, err := trustedURL(test.url)
+		if (err == nil) != test.trusted {
+			t.Errorf("trustedURL(%q) = %v, want trusted %v", test.url, err, test.trusted)
+		}
+	}
+}
+
+func TestCertificateRefetchesExpired(t *testing.T) {
+	expired, _ := newCertificate(t, time.Now().Add(-time.Hour))
+	certificatesMu.Lock()
+	certificates[testCertURL] = expired
+	certificatesMu.Unlock()
+	t.Cleanup(func() { seedCertificate(t) })
+	fresh, _ := newCertificate(t, time.Now().Add(time.Hour))
+	fetches := 0
+	serveCertificate(t, fresh, func() { fetches++ })
+
+	for i := 0; i < 2; i++ {
+		cert, err := certificate(testCertURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !cert.Equal(fresh) {
+			t.Error("got the expired certificate")
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("fetched %v times, want 1", fetches)
+	}
+}
+
+func TestCertificateFetchDoesNotBlockCached(t *testing.T) {
+	seedCertificate(t)
+	otherURL := "https://sns.us-east-1.amazonaws.com/SimpleNotificationService-other.pem"
+	t.Cleanup(func() {
+		certificatesMu.Lock()
+		delete(certificates, otherURL)
+		certificatesMu.Unlock()
+	})
+	cert, _ := newCertificate(t, time.Now().Add(time.Hour))
+	fetching := make(chan struct{})
+	release := make(chan struct{})
+	serveCertificate(t, cert, func() {
+		close(fetching)
+		<-release
+	})
+
+	done := make(chan error)
+	go func() {
+		_, err := certificate(otherURL)
+		done <- err
+	}()
+	<-fetching
+	cached := make(chan error)
+	go func() {
+		_, err := certificate(testCertURL)
+		cached <- err
+	}()
+	select {
+	case err := <-cached:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("cached certificate blocked by a fetch of another")
+	}
+	close(release)
+	err := <-done
+	if err != nil {
+		t.Fatal(err)
+	}
+}